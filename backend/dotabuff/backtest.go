@@ -0,0 +1,217 @@
+package dotabuff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BacktestMatch is a finished match to replay. Patch is the patch it was
+// stored with, empty when unknown.
+type BacktestMatch struct {
+	Match *DotabuffMatch
+	Patch string
+}
+
+// BacktestMatchesFromFiles reads matches dumped from OpenDota or the Valve
+// API, see ParseMatchJSON. Broken files are logged and skipped.
+func BacktestMatchesFromFiles(paths []string) ([]*BacktestMatch, error) {
+	files, err := MatchFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*BacktestMatch, 0, len(files))
+	for _, file := range files {
+		matches, err := ReadMatchFile(file)
+		if err != nil {
+			log.Warn().Err(err).Str("file", file).Msg("Skipping match file")
+			continue
+		}
+		for _, match := range matches {
+			res = append(res, &BacktestMatch{Match: match})
+		}
+	}
+	return res, nil
+}
+
+// BacktestMetrics scores the radiant win probabilities against the
+// results. A call of exactly 50% counts as a miss.
+type BacktestMetrics struct {
+	Matches  int
+	Correct  int
+	Accuracy float64
+	LogLoss  float64
+	Brier    float64
+}
+
+// minProbability keeps the log-loss finite for overconfident calls.
+const minProbability = 1e-6
+
+func (m *BacktestMetrics) add(radiantProb float64, radiantWon bool) {
+	y := 0.0
+	if radiantWon {
+		y = 1
+	}
+	if (radiantProb > 0.5) == radiantWon && radiantProb != 0.5 {
+		m.Correct++
+	}
+	p := math.Max(minProbability, math.Min(1-minProbability, radiantProb))
+	// the sums are turned into means by finish
+	m.LogLoss -= y*math.Log(p) + (1-y)*math.Log(1-p)
+	m.Brier += (radiantProb - y) * (radiantProb - y)
+	m.Matches++
+}
+
+func (m *BacktestMetrics) finish() {
+	if m.Matches == 0 {
+		return
+	}
+	n := float64(m.Matches)
+	m.Accuracy = float64(m.Correct) / n * 100
+	m.LogLoss /= n
+	m.Brier /= n
+}
+
+type TournamentMetrics struct {
+	Tournament string
+	BacktestMetrics
+}
+
+// BacktestReport is the result of replaying the matches through one
+// algorithm. Patches counts the matches scored with the data of the patch
+// they were played or stored with, Fallback the ones scored with the
+// current data because there was none, and Skipped the ones no data could
+// score.
+type BacktestReport struct {
+	Algorithm   string
+	Overall     BacktestMetrics
+	Tournaments []*TournamentMetrics
+	Patches     map[string]int
+	Fallback    int
+	Skipped     int
+}
+
+func (r *BacktestReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Algorithm %s: %d matches, %d skipped\n", r.Algorithm, r.Overall.Matches, r.Skipped)
+	fmt.Fprintf(&sb, "accuracy %.2f%%, log-loss %.4f, brier %.4f\n", r.Overall.Accuracy, r.Overall.LogLoss, r.Overall.Brier)
+	patches := make([]string, 0, len(r.Patches))
+	for patch, n := range r.Patches {
+		patches = append(patches, fmt.Sprintf("%s (%d)", patch, n))
+	}
+	sort.Strings(patches)
+	fmt.Fprintf(&sb, "patches: %s\n", strings.Join(patches, ", "))
+	if r.Fallback > 0 {
+		fmt.Fprintf(&sb, "%d matches of unknown patches scored with the current data\n", r.Fallback)
+	}
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "tournament\tmatches\taccuracy\tlog-loss\tbrier")
+	for _, t := range r.Tournaments {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\t%.4f\t%.4f\n", t.Tournament, t.Matches, t.Accuracy, t.LogLoss, t.Brier)
+	}
+	tw.Flush()
+	return sb.String()
+}
+
+// Backtester replays finished matches through the registered predictors.
+// Every match is scored with the counters of the patch it was played on,
+// from the release dates of the patches, when the patch store has them,
+// then with the patch it was stored with, then with the current data.
+// Nothing is fetched for past patches and nothing is stored, so it runs
+// from the cache store and a snapshot alone.
+type Backtester struct {
+	Engine *Engine
+	// Options select the counters, the patch is picked per match
+	Options PickOptions
+
+	patchStarts map[string]time.Time
+}
+
+func NewBacktester(engine *Engine, opts PickOptions) *Backtester {
+	starts, err := engine.PatchStarts()
+	if err != nil {
+		log.Warn().Err(err).Msg("Error reading patch starts, matches are scored with their stored patch")
+	}
+	return &Backtester{Engine: engine, Options: opts, patchStarts: starts}
+}
+
+// patches lists the patches whose data may score the match, best first,
+// an empty patch is the current data.
+func (b *Backtester) patches(m *BacktestMatch) []string {
+	res := make([]string, 0, 3)
+	if !m.Match.StartTime.IsZero() {
+		if patch := patchAt(b.patchStarts, m.Match.StartTime); patch != "" {
+			res = append(res, patch)
+		}
+	}
+	if m.Patch != "" && m.Patch != UnknownPatch && (len(res) == 0 || res[0] != m.Patch) {
+		res = append(res, m.Patch)
+	}
+	if b.Engine.Loaded() {
+		res = append(res, "")
+	}
+	return res
+}
+
+// Run replays the matches through the predictor of the version.
+func (b *Backtester) Run(version string, matches []*BacktestMatch) (*BacktestReport, error) {
+	p, err := PredictorFor(version)
+	if err != nil {
+		return nil, err
+	}
+	report := &BacktestReport{Algorithm: p.Version(), Patches: make(map[string]int)}
+	tournaments := make(map[string]*TournamentMetrics)
+	for _, m := range matches {
+		var prediction *Prediction
+		fallback := false
+		for _, patch := range b.patches(m) {
+			opts := b.Options
+			opts.Patch = patch
+			prediction, err = b.Engine.predict(p, m.Match, opts)
+			if err == nil {
+				fallback = patch == ""
+				break
+			}
+		}
+		if prediction == nil {
+			log.Debug().Err(err).Int64("match", m.Match.MatchID).Msg("Skipping backtest match")
+			report.Skipped++
+			continue
+		}
+		radiantProb := prediction.RadiantWin / (prediction.RadiantWin + prediction.DireWin)
+		report.Overall.add(radiantProb, m.Match.RadiantWon)
+		if fallback {
+			report.Fallback++
+		} else {
+			report.Patches[prediction.Patch]++
+		}
+		name := m.Match.TournamentLink
+		if name == "" {
+			name = "unknown"
+		}
+		t, ok := tournaments[name]
+		if !ok {
+			t = &TournamentMetrics{Tournament: name}
+			tournaments[name] = t
+		}
+		t.add(radiantProb, m.Match.RadiantWon)
+	}
+	report.Overall.finish()
+	for _, t := range tournaments {
+		t.finish()
+		report.Tournaments = append(report.Tournaments, t)
+	}
+	sort.Slice(report.Tournaments, func(i, j int) bool {
+		if report.Tournaments[i].Matches != report.Tournaments[j].Matches {
+			return report.Tournaments[i].Matches > report.Tournaments[j].Matches
+		}
+		return report.Tournaments[i].Tournament < report.Tournaments[j].Tournament
+	})
+	log.Info().Str("algorithm", report.Algorithm).Int("matches", report.Overall.Matches).Int("skipped", report.Skipped).Msg("Backtest finished")
+	return report, nil
+}
@@ -0,0 +1,72 @@
+package dotabuff
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnginePatchStarts(t *testing.T) {
+	s := newOpenDotaTestServer(t)
+	e := newFixtureEngine(t, s.source())
+	for i := 0; i < 2; i++ {
+		starts, err := e.PatchStarts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(starts) != 3 || !starts["7.36"].Equal(time.Date(2024, 5, 22, 22, 0, 0, 0, time.UTC)) {
+			t.Fatalf("got %v", starts)
+		}
+	}
+	if n := s.count("constants/patch"); n != 1 {
+		t.Fatalf("patch dates fetched %d times, want once", n)
+	}
+}
+
+func TestBacktestFallback(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	src := NewFixtureSource(heroes, nil, fixtureCounters(heroes))
+	src.SetPatch("7.37")
+	e := newFixtureEngine(t, src)
+	err := e.LoadHeroes()
+	if err == nil {
+		err = e.LoadCounters()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	err = e.store.SavePatchDates("", map[string]time.Time{"7.36": day(2024, 5, 22), "7.37": day(2024, 8, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := func(id int64, start time.Time) *BacktestMatch {
+		return &BacktestMatch{Match: &DotabuffMatch{
+			MatchID:    id,
+			Radiant:    heroes[:5],
+			Dire:       heroes[5:],
+			RadiantWon: false,
+			StartTime:  start,
+		}}
+	}
+	matches := []*BacktestMatch{
+		match(1, day(2024, 9, 1)),
+		// 7.36 has no data in the store
+		match(2, day(2024, 6, 1)),
+		// older than every known patch
+		match(3, day(2023, 1, 1)),
+	}
+	report, err := NewBacktester(e, PickOptions{}).Run(AlgorithmAverage, matches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Overall.Matches != 3 || report.Overall.Correct != 3 || report.Skipped != 0 {
+		t.Fatalf("got %+v", report.Overall)
+	}
+	if report.Patches["7.37"] != 1 || len(report.Patches) != 1 || report.Fallback != 2 {
+		t.Fatalf("got patches %v and %d fallbacks", report.Patches, report.Fallback)
+	}
+	if !strings.Contains(report.String(), "2 matches of unknown patches") {
+		t.Fatalf("fallbacks are missing from the report:\n%s", report)
+	}
+}
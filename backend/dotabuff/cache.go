@@ -0,0 +1,291 @@
+package dotabuff
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CacheSchemaVersion is bumped whenever the layout of the cached data
+// changes, entries written with another version are treated as missing.
+const CacheSchemaVersion = 1
+
+// CacheMeta describes where and when a cache entry was fetched. A zero TTL
+// means the entry never expires.
+type CacheMeta struct {
+	FetchedAt     time.Time     `json:"fetched_at"`
+	SourceURL     string        `json:"source_url"`
+	SchemaVersion int           `json:"schema_version"`
+	TTL           time.Duration `json:"ttl"`
+}
+
+func (m CacheMeta) Expired() bool {
+	return m.TTL > 0 && time.Since(m.FetchedAt) > m.TTL
+}
+
+type CacheEntry struct {
+	Meta CacheMeta       `json:"meta"`
+	Data json.RawMessage `json:"data"`
+}
+
+// CacheStore keeps entries under slash separated keys such as
+// "7.37/counters/axe". Get reports a missing, unreadable or corrupted
+// entry as not found, it never hands a broken entry to the caller.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool, error)
+	Put(key string, entry *CacheEntry) error
+	// Keys lists the keys starting with the prefix
+	Keys(prefix string) ([]string, error)
+}
+
+// NewCacheStore returns the cache backend registered under the given
+// name: "fs" keeps files in dir, "memory" keeps nothing across restarts
+// and "mysql" needs a MySQL connection.
+func NewCacheStore(name string, dir string, mysql *MySQL) (CacheStore, error) {
+	switch name {
+	case "", "fs":
+		return NewFileCache(dir), nil
+	case "memory":
+		return NewMemoryCache(), nil
+	case "mysql":
+		if mysql == nil {
+			return nil, fmt.Errorf("MySQL cache needs a MySQL connection")
+		}
+		return NewSQLCache(mysql.db), nil
+	default:
+		return nil, fmt.Errorf("Unknown cache store: %s", name)
+	}
+}
+
+func validCacheKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("Empty cache key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `\`) {
+			return fmt.Errorf("Invalid cache key: %q", key)
+		}
+	}
+	return nil
+}
+
+// decodeCacheEntry validates the envelope of an entry read by a backend.
+func decodeCacheEntry(key string, b []byte) (*CacheEntry, bool) {
+	entry := &CacheEntry{}
+	err := json.Unmarshal(b, entry)
+	if err != nil || len(entry.Data) == 0 || entry.Meta.FetchedAt.IsZero() {
+		log.Warn().Err(err).Str("key", key).Msg("Ignoring broken cache entry")
+		return nil, false
+	}
+	if entry.Meta.SchemaVersion != CacheSchemaVersion {
+		log.Info().Str("key", key).Int("version", entry.Meta.SchemaVersion).Msg("Ignoring cache entry of another schema version")
+		return nil, false
+	}
+	return entry, true
+}
+
+// FileCache keeps one JSON file per key in Dir. Files are written to a
+// temporary file first and renamed, so a crash never leaves a half
+// written entry behind.
+type FileCache struct {
+	Dir string
+}
+
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{Dir: dir}
+}
+
+func (c *FileCache) path(key string) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(key)+".json")
+}
+
+func (c *FileCache) Get(key string) (*CacheEntry, bool, error) {
+	err := validCacheKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	b, err := os.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Error reading cache entry")
+		return nil, false, nil
+	}
+	entry, ok := decodeCacheEntry(key, b)
+	return entry, ok, nil
+}
+
+func (c *FileCache) Put(key string, entry *CacheEntry) error {
+	err := validCacheKey(key)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *FileCache) Keys(prefix string) ([]string, error) {
+	res := make([]string, 0)
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(c.Dir, path)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+		if strings.HasPrefix(key, prefix) {
+			res = append(res, key)
+		}
+		return nil
+	})
+	sort.Strings(res)
+	return res, err
+}
+
+// MemoryCache keeps the entries in memory, it is meant for tests and for
+// running without a writable disk.
+type MemoryCache struct {
+	lock    sync.Mutex
+	entries map[string][]byte
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string][]byte)}
+}
+
+func (c *MemoryCache) Get(key string) (*CacheEntry, bool, error) {
+	c.lock.Lock()
+	b, ok := c.entries[key]
+	c.lock.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	entry, ok := decodeCacheEntry(key, b)
+	return entry, ok, nil
+}
+
+func (c *MemoryCache) Put(key string, entry *CacheEntry) error {
+	err := validCacheKey(key)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[key] = b
+	return nil
+}
+
+func (c *MemoryCache) Keys(prefix string) ([]string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	res := make([]string, 0)
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			res = append(res, key)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// SQLCache keeps the entries in the cache_entry table, see ddl.sql.
+type SQLCache struct {
+	db *sql.DB
+}
+
+func NewSQLCache(db *sql.DB) *SQLCache {
+	return &SQLCache{db: db}
+}
+
+func (c *SQLCache) Get(key string) (*CacheEntry, bool, error) {
+	var b []byte
+	err := c.db.QueryRow("SELECT entry FROM cache_entry WHERE cache_key = ?", key).Scan(&b)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	entry, ok := decodeCacheEntry(key, b)
+	return entry, ok, nil
+}
+
+func (c *SQLCache) Put(key string, entry *CacheEntry) error {
+	err := validCacheKey(key)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec("REPLACE INTO cache_entry (cache_key, fetched_at, entry) VALUES (?, ?, ?)", key, entry.Meta.FetchedAt, b)
+	return err
+}
+
+func (c *SQLCache) Keys(prefix string) ([]string, error) {
+	rows, err := c.db.Query("SELECT cache_key FROM cache_entry WHERE cache_key LIKE ? ORDER BY cache_key", escapeLike(prefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]string, 0)
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, key)
+	}
+	return res, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,124 @@
+package dotabuff
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cacheBackends returns the stores under test with a function writing raw
+// bytes under a key, as a crash or another version would have left them.
+func cacheBackends(t *testing.T) map[string]func() (CacheStore, func(key string, b []byte)) {
+	return map[string]func() (CacheStore, func(string, []byte)){
+		"fs": func() (CacheStore, func(string, []byte)) {
+			c := NewFileCache(t.TempDir())
+			return c, func(key string, b []byte) {
+				path := c.path(key)
+				err := os.MkdirAll(filepath.Dir(path), 0755)
+				if err == nil {
+					err = os.WriteFile(path, b, 0644)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		},
+		"memory": func() (CacheStore, func(string, []byte)) {
+			c := NewMemoryCache()
+			return c, func(key string, b []byte) {
+				c.lock.Lock()
+				defer c.lock.Unlock()
+				c.entries[key] = b
+			}
+		},
+	}
+}
+
+func testCacheEntry(t *testing.T, meta CacheMeta) []byte {
+	t.Helper()
+	b, err := json.Marshal(&CacheEntry{Meta: meta, Data: json.RawMessage(`[{"ID":2}]`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	axe, _ := HeroByID(2)
+	for name, backend := range cacheBackends(t) {
+		c, _ := backend()
+		store := NewPatchStore(c)
+		err := store.SaveHeroes("7.37", "https://example.com/heroes", []*Hero{axe})
+		if err != nil {
+			t.Fatal(err)
+		}
+		heroes, ok, err := store.LoadHeroes("7.37")
+		if err != nil || !ok || len(heroes) != 1 || heroes[0].ID != 2 {
+			t.Fatalf("%s: got %v %v %v", name, heroes, ok, err)
+		}
+		keys, err := c.Keys("7.37/")
+		if err != nil || len(keys) != 1 || keys[0] != "7.37/heroes" {
+			t.Fatalf("%s: got keys %v %v", name, keys, err)
+		}
+	}
+}
+
+func TestCacheTruncatedEntry(t *testing.T) {
+	for name, backend := range cacheBackends(t) {
+		c, write := backend()
+		b := testCacheEntry(t, CacheMeta{FetchedAt: time.Now(), SchemaVersion: CacheSchemaVersion})
+		write("7.37/heroes", b[:len(b)/2])
+		_, ok, err := c.Get("7.37/heroes")
+		if ok || err != nil {
+			t.Fatalf("%s: truncated entry gives %v %v", name, ok, err)
+		}
+		_, ok, err = NewPatchStore(c).LoadHeroes("7.37")
+		if ok || err != nil {
+			t.Fatalf("%s: truncated heroes give %v %v", name, ok, err)
+		}
+	}
+}
+
+func TestCacheSchemaVersion(t *testing.T) {
+	for name, backend := range cacheBackends(t) {
+		c, write := backend()
+		write("7.37/heroes", testCacheEntry(t, CacheMeta{FetchedAt: time.Now(), SchemaVersion: CacheSchemaVersion + 1}))
+		_, ok, err := c.Get("7.37/heroes")
+		if ok || err != nil {
+			t.Fatalf("%s: entry of another schema gives %v %v", name, ok, err)
+		}
+		write("7.37/heroes", testCacheEntry(t, CacheMeta{FetchedAt: time.Now(), SchemaVersion: CacheSchemaVersion}))
+		_, ok, err = c.Get("7.37/heroes")
+		if !ok || err != nil {
+			t.Fatalf("%s: valid entry gives %v %v", name, ok, err)
+		}
+	}
+}
+
+func TestCacheExpiredTTL(t *testing.T) {
+	axe, _ := HeroByID(2)
+	for name, backend := range cacheBackends(t) {
+		c, write := backend()
+		store := NewPatchStore(c)
+		key, err := store.key("7.37", "counters", axe.Slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(key, testCacheEntry(t, CacheMeta{
+			FetchedAt:     time.Now().Add(-2 * CountersTTL),
+			SchemaVersion: CacheSchemaVersion,
+			TTL:           CountersTTL,
+		}))
+		_, ok, err := store.LoadCounters("7.37", CounterFilter{}, axe, true)
+		if ok || err != nil {
+			t.Fatalf("%s: expired counters are fresh: %v %v", name, ok, err)
+		}
+		// past patches accept stale counters
+		counters, ok, err := store.LoadCounters("7.37", CounterFilter{}, axe, false)
+		if !ok || err != nil || len(counters) != 1 {
+			t.Fatalf("%s: expired counters give %v %v %v", name, counters, ok, err)
+		}
+	}
+}
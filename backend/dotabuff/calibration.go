@@ -0,0 +1,254 @@
+package dotabuff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// StoredPrediction is a prediction stored in dotabuff_match together with
+// the result of the match.
+type StoredPrediction struct {
+	MatchID          int64
+	AlgorithmVersion string
+	RadiantWin       float64
+	DireWin          float64
+	RadiantWon       bool
+	// PredictedAt is zero for the rows stored before it was kept
+	PredictedAt time.Time
+}
+
+// favoredRadiant is the side the prediction calls, the same way as the
+// radiant_won_prediction column.
+func (p *StoredPrediction) favoredRadiant() bool {
+	return p.RadiantWin > p.DireWin
+}
+
+// Confidence is the win probability of the favored side in percent, from
+// 50 to 100.
+func (p *StoredPrediction) Confidence() float64 {
+	total := p.RadiantWin + p.DireWin
+	if total <= 0 {
+		return 50
+	}
+	radiant := p.RadiantWin / total * 100
+	if p.favoredRadiant() {
+		return radiant
+	}
+	return 100 - radiant
+}
+
+func (p *StoredPrediction) Hit() bool {
+	return p.favoredRadiant() == p.RadiantWon
+}
+
+// calibrationBucketWidth is the width of the confidence buckets in
+// percent points, the first bucket starts at 50.
+const calibrationBucketWidth = 5
+
+// CalibrationBucket holds the predictions whose confidence is in
+// [Low, High). A calibrated model has a HitRate close to MeanConfidence.
+type CalibrationBucket struct {
+	Low            float64 `json:"low"`
+	High           float64 `json:"high"`
+	Predictions    int     `json:"predictions"`
+	Hits           int     `json:"hits"`
+	MeanConfidence float64 `json:"mean_confidence"`
+	HitRate        float64 `json:"hit_rate"`
+}
+
+// CalibrationWeek is the accuracy of the predictions made in the ISO
+// week, e.g. "2024-W07".
+type CalibrationWeek struct {
+	Week        string  `json:"week"`
+	Predictions int     `json:"predictions"`
+	Hits        int     `json:"hits"`
+	Accuracy    float64 `json:"accuracy"`
+}
+
+type AlgorithmCalibration struct {
+	Algorithm   string               `json:"algorithm"`
+	Predictions int                  `json:"predictions"`
+	Hits        int                  `json:"hits"`
+	Accuracy    float64              `json:"accuracy"`
+	Buckets     []*CalibrationBucket `json:"buckets"`
+	Weeks       []*CalibrationWeek   `json:"weeks"`
+}
+
+// CalibrationReport compares the stored predictions with the results, per
+// algorithm version. Percentages are in percent.
+type CalibrationReport struct {
+	GeneratedAt time.Time               `json:"generated_at"`
+	Algorithms  []*AlgorithmCalibration `json:"algorithms"`
+}
+
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// NewCalibrationReport buckets the predictions by algorithm version and
+// confidence. Predictions without a prediction time count in the totals
+// but not in the weeks.
+func NewCalibrationReport(predictions []*StoredPrediction) *CalibrationReport {
+	type acc struct {
+		calibration *AlgorithmCalibration
+		confidence  []float64
+		weeks       map[string]*CalibrationWeek
+	}
+	byVersion := make(map[string]*acc)
+	buckets := (100 - 50) / calibrationBucketWidth
+	for _, p := range predictions {
+		version := p.AlgorithmVersion
+		if version == "" {
+			version = "unknown"
+		}
+		a, ok := byVersion[version]
+		if !ok {
+			a = &acc{
+				calibration: &AlgorithmCalibration{Algorithm: version},
+				confidence:  make([]float64, buckets),
+				weeks:       make(map[string]*CalibrationWeek),
+			}
+			for i := 0; i < buckets; i++ {
+				a.calibration.Buckets = append(a.calibration.Buckets, &CalibrationBucket{
+					Low:  float64(50 + i*calibrationBucketWidth),
+					High: float64(50 + (i+1)*calibrationBucketWidth),
+				})
+			}
+			byVersion[version] = a
+		}
+		c := a.calibration
+		confidence := p.Confidence()
+		i := int((confidence - 50) / calibrationBucketWidth)
+		if i >= buckets {
+			i = buckets - 1
+		}
+		hit := 0
+		if p.Hit() {
+			hit = 1
+		}
+		c.Predictions++
+		c.Hits += hit
+		c.Buckets[i].Predictions++
+		c.Buckets[i].Hits += hit
+		a.confidence[i] += confidence
+		if !p.PredictedAt.IsZero() {
+			key := isoWeek(p.PredictedAt)
+			w, ok := a.weeks[key]
+			if !ok {
+				w = &CalibrationWeek{Week: key}
+				a.weeks[key] = w
+			}
+			w.Predictions++
+			w.Hits += hit
+		}
+	}
+	report := &CalibrationReport{GeneratedAt: time.Now().UTC(), Algorithms: make([]*AlgorithmCalibration, 0, len(byVersion))}
+	for _, a := range byVersion {
+		c := a.calibration
+		c.Accuracy = percent(c.Hits, c.Predictions)
+		for i, b := range c.Buckets {
+			b.HitRate = percent(b.Hits, b.Predictions)
+			if b.Predictions > 0 {
+				b.MeanConfidence = a.confidence[i] / float64(b.Predictions)
+			}
+		}
+		c.Weeks = make([]*CalibrationWeek, 0, len(a.weeks))
+		for _, w := range a.weeks {
+			w.Accuracy = percent(w.Hits, w.Predictions)
+			c.Weeks = append(c.Weeks, w)
+		}
+		sort.Slice(c.Weeks, func(i, j int) bool { return c.Weeks[i].Week < c.Weeks[j].Week })
+		report.Algorithms = append(report.Algorithms, c)
+	}
+	sort.Slice(report.Algorithms, func(i, j int) bool {
+		return report.Algorithms[i].Algorithm < report.Algorithms[j].Algorithm
+	})
+	return report
+}
+
+// Algorithm keeps only the calibration of the version.
+func (r *CalibrationReport) Algorithm(version string) *CalibrationReport {
+	res := &CalibrationReport{GeneratedAt: r.GeneratedAt, Algorithms: make([]*AlgorithmCalibration, 0, 1)}
+	for _, c := range r.Algorithms {
+		if c.Algorithm == version {
+			res.Algorithms = append(res.Algorithms, c)
+		}
+	}
+	return res
+}
+
+func (r *CalibrationReport) String() string {
+	var sb strings.Builder
+	for _, c := range r.Algorithms {
+		fmt.Fprintf(&sb, "Algorithm %s: %d predictions, accuracy %.2f%%\n", c.Algorithm, c.Predictions, c.Accuracy)
+		tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "confidence\tpredictions\tmean confidence\thit rate")
+		for _, b := range c.Buckets {
+			if b.Predictions == 0 {
+				continue
+			}
+			fmt.Fprintf(tw, "%.0f-%.0f%%\t%d\t%.2f%%\t%.2f%%\n", b.Low, b.High, b.Predictions, b.MeanConfidence, b.HitRate)
+		}
+		tw.Flush()
+		tw = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "week\tpredictions\taccuracy")
+		for _, w := range c.Weeks {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f%%\n", w.Week, w.Predictions, w.Accuracy)
+		}
+		tw.Flush()
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// ReliabilityDiagram plots the hit rate against the confidence of every
+// algorithm with reliability.py, the format follows the extension of the
+// path: .png or .svg.
+func (r *CalibrationReport) ReliabilityDiagram(path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".png" && ext != ".svg" {
+		return fmt.Errorf("Unsupported diagram format %q, use .png or .svg", ext)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("python3", "reliability.py",
+		fmt.Sprintf("--data=%s", data),
+		fmt.Sprintf("--output=%s", path))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Error().Err(err).Str("output", string(out)).Msg("Error plotting reliability diagram")
+		return fmt.Errorf("Error plotting reliability diagram: %v", err)
+	}
+	return nil
+}
+
+// CalibrationReport builds the report from the predictions stored in
+// MySQL.
+func (e *Engine) CalibrationReport() (*CalibrationReport, error) {
+	if e.mysql == nil {
+		return nil, fmt.Errorf("MySQL is not configured")
+	}
+	predictions, err := e.mysql.StoredPredictions()
+	if err != nil {
+		return nil, err
+	}
+	return NewCalibrationReport(predictions), nil
+}
@@ -0,0 +1,35 @@
+package dotabuff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalibrationReport(t *testing.T) {
+	monday := time.Date(2024, 2, 12, 10, 0, 0, 0, time.UTC)
+	predictions := []*StoredPrediction{
+		{MatchID: 1, AlgorithmVersion: AlgorithmAverage, RadiantWin: 52, DireWin: 48, RadiantWon: true, PredictedAt: monday},
+		{MatchID: 2, AlgorithmVersion: AlgorithmAverage, RadiantWin: 40, DireWin: 60, RadiantWon: true, PredictedAt: monday.Add(24 * time.Hour)},
+		{MatchID: 3, AlgorithmVersion: AlgorithmAverage, RadiantWin: 97, DireWin: 3, RadiantWon: true, PredictedAt: monday.Add(7 * 24 * time.Hour)},
+		// stored before predicted_at was kept
+		{MatchID: 4, AlgorithmVersion: AlgorithmAverage, RadiantWin: 45, DireWin: 55, RadiantWon: false},
+		{MatchID: 1, AlgorithmVersion: AlgorithmLogOdds, RadiantWin: 51, DireWin: 49, RadiantWon: true, PredictedAt: monday},
+	}
+	report := NewCalibrationReport(predictions)
+	if len(report.Algorithms) != 2 || report.Algorithms[0].Algorithm != AlgorithmAverage {
+		t.Fatalf("got %+v", report.Algorithms)
+	}
+	c := report.Algorithms[0]
+	if c.Predictions != 4 || c.Hits != 3 || c.Accuracy != 75 {
+		t.Fatalf("got %d predictions, %d hits and %v%%", c.Predictions, c.Hits, c.Accuracy)
+	}
+	if b := c.Buckets[0]; b.Predictions != 1 || b.Hits != 1 || b.MeanConfidence != 52 {
+		t.Fatalf("got first bucket %+v", b)
+	}
+	if b := c.Buckets[len(c.Buckets)-1]; b.Predictions != 1 || b.MeanConfidence != 97 {
+		t.Fatalf("got last bucket %+v", b)
+	}
+	if len(c.Weeks) != 2 || c.Weeks[0].Week != "2024-W07" || c.Weeks[0].Predictions != 2 || c.Weeks[0].Hits != 1 || c.Weeks[1].Week != "2024-W08" {
+		t.Fatalf("got weeks %+v", c.Weeks)
+	}
+}
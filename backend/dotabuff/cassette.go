@@ -0,0 +1,76 @@
+package dotabuff
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+type CassetteMode int
+
+const (
+	CassetteRecord CassetteMode = iota + 1
+	CassetteReplay
+)
+
+// Cassette stores HTTP responses in a directory, one JSON file per URL.
+// In record mode the Fetcher saves every response it gets, in replay mode
+// it never goes to the network and fails on URLs that were not recorded.
+type Cassette struct {
+	Dir  string
+	Mode CassetteMode
+}
+
+type CassetteEntry struct {
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"headers"`
+	Body   string      `json:"body"`
+}
+
+func NewCassette(dir string, mode CassetteMode) (*Cassette, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &Cassette{Dir: dir, Mode: mode}, nil
+}
+
+func (c *Cassette) path(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cassette) Load(url string) (*CassetteEntry, error) {
+	b, err := os.ReadFile(c.path(url))
+	if err != nil {
+		log.Error().Str("url", url).Str("dir", c.Dir).Msg("Cassette miss")
+		return nil, fmt.Errorf("Cassette %s has no recording of %s", c.Dir, url)
+	}
+	entry := &CassetteEntry{}
+	err = json.Unmarshal(b, entry)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding cassette entry of %s: %v", url, err)
+	}
+	return entry, nil
+}
+
+func (c *Cassette) Save(entry *CassetteEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := c.path(entry.URL)
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,97 @@
+package dotabuff
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// replayCassette makes the package level parsers replay the recordings of
+// testdata/cassette.
+func replayCassette(t *testing.T) {
+	t.Helper()
+	f := NewFetcher(time.Second, 0)
+	f.Cassette = &Cassette{Dir: filepath.Join("testdata", "cassette"), Mode: CassetteReplay}
+	previous := DefaultFetcher
+	DefaultFetcher = f
+	t.Cleanup(func() { DefaultFetcher = previous })
+}
+
+func TestCassetteHeroes(t *testing.T) {
+	replayCassette(t)
+	heroes, err := Heroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heroes) != 10 {
+		t.Fatalf("got %d heroes, want 10", len(heroes))
+	}
+	sort.Slice(heroes, func(i, j int) bool { return heroes[i].ID < heroes[j].ID })
+	for i, hero := range heroes {
+		if hero.ID != i+1 {
+			t.Fatalf("got hero %d at %d", hero.ID, i)
+		}
+	}
+}
+
+func TestCassetteCounters(t *testing.T) {
+	replayCassette(t)
+	am, _ := HeroBySlug("anti-mage")
+	counters, err := am.Counters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counters) != 3 {
+		t.Fatalf("got %d counters, want 3", len(counters))
+	}
+	axe, bane := counters[0], counters[1]
+	if axe.Hero.ID != 2 || axe.Disadvantage != 2.5 || axe.WinRate != 55.1 || axe.MatchesPlayed != 123456 {
+		t.Fatalf("got %+v", axe)
+	}
+	if bane.Hero.Slug != "bane" || bane.Disadvantage != -1.25 {
+		t.Fatalf("got %+v", bane)
+	}
+}
+
+func TestCassetteMatch(t *testing.T) {
+	replayCassette(t)
+	match, err := ExtractHerosFromDBLink("https://www.dotabuff.com/matches/7000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match.MatchID != 7000000001 || len(match.Radiant) != 5 || len(match.Dire) != 5 {
+		t.Fatalf("got %+v", match)
+	}
+	if match.Radiant[0].Slug != "anti-mage" || match.Dire[4].Slug != "morphling" {
+		t.Fatalf("got radiant %v and dire %v", match.Radiant, match.Dire)
+	}
+	if !match.RadiantWon || match.RadiantTeam.Name != "Tundra Esports" || match.DireTeam.Name != "Team Liquid" {
+		t.Fatalf("got teams %q and %q", match.RadiantTeam.Name, match.DireTeam.Name)
+	}
+	if match.TournamentLink != "https://www.dotabuff.com/esports/leagues/16935-the-international-2024" {
+		t.Fatalf("got tournament %s", match.TournamentLink)
+	}
+	if match.Duration != 40*time.Minute+5*time.Second || match.GameMode != "Captains Mode" || match.Region != "Europe West" {
+		t.Fatalf("got details %v %q %q", match.Duration, match.GameMode, match.Region)
+	}
+	if len(match.Players) != 10 || match.Players[0].Name != "player0" || len(match.Players[0].Items) != 2 {
+		t.Fatalf("got players %+v", match.Players)
+	}
+	if len(match.Draft) != 12 || match.Draft[0].Order != 1 || match.Draft[0].Pick || !match.Draft[0].Radiant {
+		t.Fatalf("got draft %+v", match.Draft)
+	}
+}
+
+func TestCassetteMiss(t *testing.T) {
+	replayCassette(t)
+	_, err := ExtractHerosFromDBMatch(1)
+	if !fetchErrorIs(err, FetchCassetteMiss) {
+		t.Fatalf("got %v, want a cassette miss", err)
+	}
+	bane, _ := HeroBySlug("bane")
+	_, err = bane.Counters()
+	if !fetchErrorIs(err, FetchCassetteMiss) {
+		t.Fatalf("got %v, want a cassette miss", err)
+	}
+}
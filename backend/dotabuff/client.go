@@ -3,9 +3,8 @@ package dotabuff
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -17,6 +16,11 @@ import (
 	"golang.org/x/text/language"
 )
 
+const (
+	DotabuffHeroesURL       = "https://www.dotabuff.com/heroes"
+	DotabuffSideWinratesURL = "https://www.dotabuff.com/heroes/meta?view=played&metric=faction"
+)
+
 type DotabuffMatch struct {
 	MatchID        int64
 	Dire           []*Hero
@@ -25,6 +29,36 @@ type DotabuffMatch struct {
 	RadiantTeam    *Team
 	RadiantWon     bool
 	TournamentLink string
+
+	// match details, zero when the page or the API does not have them
+	StartTime time.Time
+	Duration  time.Duration
+	GameMode  string
+	LobbyType string
+	Region    string
+	Players   []*MatchPlayer
+	// Draft is the pick/ban order, empty for matches without one
+	Draft []*DraftAction
+}
+
+// MatchPlayer is the end of game state of a single player.
+type MatchPlayer struct {
+	// AccountID is zero for anonymous players
+	AccountID int64
+	Name      string
+	Hero      *Hero
+	Radiant   bool
+	Kills     int
+	Deaths    int
+	Assists   int
+	NetWorth  int
+	Level     int
+	// Items are the final inventory item names, e.g. "black_king_bar"
+	Items []string
+	// Lane is one of the Lane constants, empty when unknown
+	Lane string
+	// Position is 1 for the carry up to 5 for the hard support
+	Position int
 }
 
 type RadiantDireWinrate struct {
@@ -33,6 +67,9 @@ type RadiantDireWinrate struct {
 	RadiantPickRate float64
 	DireWinrate     float64
 	DirePickRate    float64
+	// Unsplit is set when the source has no per side numbers, both sides
+	// then hold the overall winrate and pick rate
+	Unsplit bool
 }
 
 type Team struct {
@@ -40,9 +77,14 @@ type Team struct {
 	Link string
 }
 
+// Hero is identified by its Valve hero ID, heroes coming from a data
+// source are resolved against the registry with CanonicalHero.
 type Hero struct {
-	Name string
-	Link string
+	ID      int
+	NpcName string
+	Slug    string
+	Name    string
+	Link    string
 }
 
 type Counter struct {
@@ -61,42 +103,43 @@ func (h *Hero) WinRateVsPick(pick []*Counter, radiant bool, SideMultiplier float
 	return averageWR * SideMultiplier
 }
 
+// Counters fetches the default counters page of the hero from dotabuff.
 func (h *Hero) Counters() ([]*Counter, error) {
-	// if file counters.json exists, return counters from it
-	// if not, fetch counters from dotabuff and save them to counters.json
-	_ = os.Mkdir("counters", 0755)
-	info, err := os.Stat(fmt.Sprintf("counters/%s.json", h.Name))
-	needToUpdate := err != nil || time.Since(info.ModTime()) > 24*time.Hour
-	countersJson, err := os.ReadFile(fmt.Sprintf("counters/%s.json", h.Name))
-	if err == nil && !needToUpdate {
-		log.Info().Msg("Counters file found, parsing...")
-		counters, err := ParseCounters(countersJson)
-		if err != nil {
-			return nil, err
-		}
-		return counters, nil
-	} else {
-		log.Info().Msg("Counters file not found, fetching from dotabuff...")
-		parsed, err := getAndParse(h.Link + "/counters")
-		if err != nil {
-			return nil, err
-		}
-		res := make([]*Counter, 0)
-		find := htmlquery.Find(parsed, "//table/tbody/tr[@data-link-to]")
-		for _, n := range find {
-			counter, err := CounterNodeToCounter(n)
-			if err != nil {
-				log.Printf("Error parsing counter: %v", err)
-				continue
-			}
-			res = append(res, counter)
-		}
-		err = SaveCounters(res, h.Name)
+	return h.CountersWithFilter(CounterFilter{})
+}
+
+func (h *Hero) CountersURL(filter CounterFilter) string {
+	return h.Link + "/counters" + filter.Query()
+}
+
+// CountersWithFilter fetches the counters page of the hero for the time
+// window and skill bracket of the filter.
+func (h *Hero) CountersWithFilter(filter CounterFilter) ([]*Counter, error) {
+	log.Info().Str("hero", h.Name).Str("filter", filter.String()).Msg("Fetching counters from dotabuff...")
+	link := h.CountersURL(filter)
+	parsed, err := getAndParse(link)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCountersPage(link, parsed)
+}
+
+// ParseCountersPage reads the counters table of a parsed dotabuff counters
+// page, a row that does not parse means the layout changed.
+func ParseCountersPage(link string, root *html.Node) ([]*Counter, error) {
+	res := make([]*Counter, 0)
+	find := htmlquery.Find(root, "//table/tbody/tr[@data-link-to]")
+	for _, n := range find {
+		counter, err := CounterNodeToCounter(link, n)
 		if err != nil {
 			return nil, err
 		}
-		return res, nil
+		res = append(res, counter)
+	}
+	if len(res) == 0 {
+		return nil, &ParseError{URL: link, Element: "counter rows"}
 	}
+	return res, nil
 }
 
 func ParseCounters(countersJson []byte) ([]*Counter, error) {
@@ -108,18 +151,6 @@ func ParseCounters(countersJson []byte) ([]*Counter, error) {
 	return res, nil
 }
 
-func SaveCounters(res []*Counter, name string) (err error) {
-	b, err := json.Marshal(res)
-	if err != nil {
-		return err
-	}
-	err = os.WriteFile(fmt.Sprintf("counters/%s.json", name), b, 0644)
-	if err != nil {
-		return err
-	}
-	return
-}
-
 func NodeToString(n *html.Node) string {
 	var b bytes.Buffer
 	err := html.Render(&b, n)
@@ -135,16 +166,28 @@ func ExtractHerosFromDBLink(link string) (*DotabuffMatch, error) {
 	if err != nil {
 		return nil, err
 	}
-	radiant, rTeam, rWon := ParseSide("radiant", parsed)
-	dire, dTeam, _ := ParseSide("dire", parsed)
+	return ParseMatchPage(link, parsed)
+}
+
+// ParseMatchPage parses a dotabuff match page, link is the URL of the page.
+func ParseMatchPage(link string, parsed *html.Node) (*DotabuffMatch, error) {
+	radiant, rTeam, rWon, err := ParseSide(link, "radiant", parsed)
+	if err != nil {
+		return nil, err
+	}
+	dire, dTeam, dWon, err := ParseSide(link, "dire", parsed)
+	if err != nil {
+		return nil, err
+	}
+	if rWon == dWon {
+		return nil, &ParseError{URL: link, Element: "winner marker of exactly one side"}
+	}
 	parsedTournamentLink := ParseTournamentLink(parsed)
-	matchIdStr := strings.TrimPrefix(link, "https://www.dotabuff.com/matches/")
-	matchId, err := strconv.ParseInt(matchIdStr, 10, 64)
+	matchId, err := MatchIDFromDBLink(link)
 	if err != nil {
-		log.Printf("Error parsing match id: %v", err)
 		return nil, err
 	}
-	return &DotabuffMatch{
+	match := &DotabuffMatch{
 		MatchID:        matchId,
 		Dire:           dire,
 		Radiant:        radiant,
@@ -152,11 +195,31 @@ func ExtractHerosFromDBLink(link string) (*DotabuffMatch, error) {
 		RadiantTeam:    rTeam,
 		RadiantWon:     rWon,
 		TournamentLink: parsedTournamentLink,
-	}, nil
+	}
+	ParseMatchDetails(link, parsed, match)
+	match.Draft, err = ParseDraft(link, parsed)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error parsing the pick/ban order")
+	}
+	return match, nil
+}
+
+// MatchIDFromDBLink returns the ID of a match link, see ParseMatchRef for
+// the accepted forms.
+func MatchIDFromDBLink(link string) (int64, error) {
+	matchId, err := ParseMatchRef(link)
+	if err != nil {
+		log.Printf("Error parsing match id: %v", err)
+		return 0, err
+	}
+	return matchId, nil
 }
 
 func ParseTournamentLink(root *html.Node) string {
 	a := htmlquery.FindOne(root, "//dd/a[@class='esports-link']")
+	if a == nil {
+		return ""
+	}
 	link := htmlquery.SelectAttr(a, "href")
 	return fmt.Sprintf("https://www.dotabuff.com%v", link)
 }
@@ -166,114 +229,127 @@ func ExtractHerosFromDBMatch(id int64) (*DotabuffMatch, error) {
 	return ExtractHerosFromDBLink(link)
 }
 
-func ParseSide(side string, root *html.Node) ([]*Hero, *Team, bool) {
-	section := htmlquery.FindOne(root, fmt.Sprintf("//section[@class='%s']", side))
-	sectionChilds := ChildArray(section)
-	header := sectionChilds[0]
-	headerChilds := ChildArray(header)
-	a := headerChilds[0]
-	aHref := htmlquery.SelectAttr(a, "href")
-	aChilds := ChildArray(a)
-	spanWTeamName := aChilds[1]
-	teamName := htmlquery.InnerText(spanWTeamName)
-	article := sectionChilds[1]
-	articleChilds := ChildArray(article)
-	table := articleChilds[0]
-	tableChilds := ChildArray(table)
-	tbodyTwo := tableChilds[1]
-	tbodyTwoChilds := ChildArray(tbodyTwo)
-	heroes := make([]*Hero, 0)
-	for _, tr := range tbodyTwoChilds {
-		hero := HeroFromTr(tr)
-		heroes = append(heroes, hero)
-	}
-	team := &Team{
-		Name: teamName,
-		Link: fmt.Sprintf("https://www.dotabuff.com%v", aHref),
-	}
-	return heroes, team, len(headerChilds) > 1
+// ParseError means that an element the parser relies on is missing from
+// the page, which almost always means that dotabuff changed its layout.
+type ParseError struct {
+	URL     string
+	Element string
 }
 
-func HeroFromTr(tr *html.Node) *Hero {
-	trChilds := ChildArray(tr)
-	td := trChilds[0]
-	tdChilds := ChildArray(td)
-	div := tdChilds[0]
-	divChilds := ChildArray(div)
-	div2 := divChilds[0]
-	div2Childs := ChildArray(div2)
-	div3 := div2Childs[0]
-	div3Childs := ChildArray(div3)
-	a := div3Childs[2]
-	link := htmlquery.SelectAttr(a, "href")
-	return DotaHeroFromLink(link)
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("dotabuff layout changed: %s not found on %s", e.Element, e.URL)
 }
 
-func getAndParse(url string) (*html.Node, error) {
-	return getAndParse0(url, 0)
+func IsLayoutChanged(err error) bool {
+	var pe *ParseError
+	return errors.As(err, &pe)
 }
 
-func getAndParse0(url string, retry int) (*html.Node, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Error().Err(err).Msg("Error fetching page")
-		return nil, err
+// playerRowsXPath selects the player rows of a side section.
+const playerRowsXPath = "./article//table/tbody/tr[.//a[starts-with(@href, '/heroes/')]]"
+
+// winnerXPath selects the winner marker in the header of a side section.
+const winnerXPath = ".//*[contains(concat(' ', normalize-space(@class), ' '), ' victory-icon ') or normalize-space(.) = 'Winner']"
+
+func sideSection(root *html.Node, side string) *html.Node {
+	return htmlquery.FindOne(root, fmt.Sprintf("//section[contains(concat(' ', normalize-space(@class), ' '), ' %s ')]", side))
+}
+
+func ParseSide(link string, side string, root *html.Node) ([]*Hero, *Team, bool, error) {
+	section := sideSection(root, side)
+	if section == nil {
+		return nil, nil, false, &ParseError{URL: link, Element: side + " section"}
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 429 {
-		log.Warn().Msg("Rate limited")
-		sleepSec := 10 * (retry + 1)
-		log.Info().Int("sleep-sec", sleepSec).Msg("Sleeping...")
-		time.Sleep(time.Duration(sleepSec) * time.Second)
-		return getAndParse0(url, retry+1)
+	header := htmlquery.FindOne(section, "./header")
+	if header == nil {
+		return nil, nil, false, &ParseError{URL: link, Element: side + " header"}
 	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Error fetching page: %v", resp.Status)
+	team := &Team{}
+	a := htmlquery.FindOne(header, "./a[@href]")
+	if a != nil {
+		team.Link = fmt.Sprintf("https://www.dotabuff.com%v", htmlquery.SelectAttr(a, "href"))
+		if span := htmlquery.FindOne(a, ".//span[normalize-space(.) != '']"); span != nil {
+			team.Name = strings.TrimSpace(htmlquery.InnerText(span))
+		}
 	}
-	parsed, err := htmlquery.Parse(resp.Body)
-	if err != nil {
-		log.Error().Err(err).Msg("Error parsing body")
-		return nil, err
+	winner := htmlquery.FindOne(header, winnerXPath)
+	if team.Name == "" {
+		// the text of the header itself, without the winner marker
+		for c := header.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				team.Name += c.Data
+			}
+		}
+		team.Name = strings.TrimSpace(team.Name)
+	}
+	if team.Name == "" {
+		team.Name = strings.ToUpper(side[:1]) + side[1:]
 	}
-	return parsed, nil
+	won := winner != nil
+	rows := htmlquery.Find(section, playerRowsXPath)
+	if len(rows) != 5 {
+		return nil, nil, false, &ParseError{URL: link, Element: fmt.Sprintf("5 %s player rows (got %d)", side, len(rows))}
+	}
+	heroes := make([]*Hero, 0, len(rows))
+	for _, tr := range rows {
+		hero, err := HeroFromTr(link, tr)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		heroes = append(heroes, hero)
+	}
+	return heroes, team, won, nil
+}
+
+func HeroFromTr(link string, tr *html.Node) (*Hero, error) {
+	a := htmlquery.FindOne(tr, ".//a[starts-with(@href, '/heroes/')]")
+	if a == nil {
+		return nil, &ParseError{URL: link, Element: "hero link"}
+	}
+	return DotaHeroFromLink(htmlquery.SelectAttr(a, "href")), nil
 }
 
-func CounterNodeToCounter(n *html.Node) (*Counter, error) {
-	childs := ChildArray(n)
-	if len(childs) != 5 {
-		log.Error().Int("childs-len", len(childs)).Msg("Invalid amount of childs")
-		return nil, fmt.Errorf("Invalid amount of childs")
+func getAndParse(url string) (*html.Node, error) {
+	return DefaultFetcher.GetAndParse(url)
+}
+
+// CounterNodeToCounter reads a row of the counters table: the hero link,
+// then the disadvantage, the winrate and the matches played as the
+// data-value of the third to the fifth cell.
+func CounterNodeToCounter(link string, n *html.Node) (*Counter, error) {
+	cells := htmlquery.Find(n, "./td")
+	if len(cells) < 5 {
+		return nil, &ParseError{URL: link, Element: fmt.Sprintf("5 counter cells (got %d)", len(cells))}
+	}
+	a := htmlquery.FindOne(n, "./td//a[starts-with(@href, '/heroes/')]")
+	if a == nil {
+		return nil, &ParseError{URL: link, Element: "counter hero link"}
 	}
-	heroName := htmlquery.SelectAttr(childs[0], "data-value")
-	heroLink := htmlquery.SelectAttr(ChildArray(childs[1])[0], "href")
-	disadvantage := htmlquery.SelectAttr(childs[2], "data-value")
-	winRate := htmlquery.SelectAttr(childs[3], "data-value")
-	matchesPlayed := htmlquery.SelectAttr(childs[4], "data-value")
-	disParsed, err := strconv.ParseFloat(disadvantage, 64)
+	heroName := htmlquery.SelectAttr(cells[0], "data-value")
+	if heroName == "" {
+		heroName = strings.TrimSpace(htmlquery.InnerText(a))
+	}
+	disParsed, err := strconv.ParseFloat(htmlquery.SelectAttr(cells[2], "data-value"), 64)
 	if err != nil {
-		log.Printf("Error parsing disadvantage: %v", err)
-		return nil, err
+		return nil, &ParseError{URL: link, Element: "counter disadvantage"}
 	}
-	winParsed, err := strconv.ParseFloat(winRate, 64)
+	winParsed, err := strconv.ParseFloat(htmlquery.SelectAttr(cells[3], "data-value"), 64)
 	if err != nil {
-		log.Printf("Error parsing win rate: %v", err)
-		return nil, err
+		return nil, &ParseError{URL: link, Element: "counter win rate"}
 	}
-	matchesParsed, err := strconv.ParseInt(matchesPlayed, 10, 64)
+	matchesParsed, err := strconv.ParseInt(htmlquery.SelectAttr(cells[4], "data-value"), 10, 64)
 	if err != nil {
-		log.Printf("Error parsing matches played: %v", err)
-		return nil, err
+		return nil, &ParseError{URL: link, Element: "counter matches played"}
 	}
 	return &Counter{
-		Hero: &Hero{
+		Hero: CanonicalHero(&Hero{
 			Name: heroName,
-			Link: fmt.Sprintf("https://www.dotabuff.com%v", heroLink),
-		},
+			Link: fmt.Sprintf("https://www.dotabuff.com%v", htmlquery.SelectAttr(a, "href")),
+		}),
 		Disadvantage:  disParsed,
 		WinRate:       winParsed,
 		MatchesPlayed: matchesParsed,
 	}, nil
-
 }
 
 func ChildArray(n *html.Node) []*html.Node {
@@ -284,91 +360,70 @@ func ChildArray(n *html.Node) []*html.Node {
 	return res
 }
 
+// RaidantAndDireWR fetches the radiant and dire winrates of every hero
+// from dotabuff.
 func RaidantAndDireWR() ([]*RadiantDireWinrate, error) {
-	// if file radiant_dire_winrate.json exists, return heroes from it
-	// if not, fetch heroes from dotabuff and save them to radiant_dire_winrate.json
-	radiantDireWinrateJson, err := os.ReadFile("radiant_dire_winrate.json")
-	if err == nil {
-		log.Info().Msg("RadiantDireWinrate file found, parsing...")
-		radiantDireWinrate, err := ParseRadiantDireWinrate(radiantDireWinrateJson)
+	log.Info().Msg("Fetching radiant and dire winrates from dotabuff...")
+	link := DotabuffSideWinratesURL
+	parsed, err := getAndParse(link)
+	if err != nil {
+		return nil, err
+	}
+	tbody := htmlquery.FindOne(parsed, "//section/footer/article/table/tbody")
+	if tbody == nil {
+		return nil, &ParseError{URL: link, Element: "faction table"}
+	}
+	res := make([]*RadiantDireWinrate, 0)
+	for _, tr := range htmlquery.Find(tbody, "./tr") {
+		trChilds := htmlquery.Find(tr, "./td")
+		if len(trChilds) < 6 {
+			return nil, &ParseError{URL: link, Element: "faction table cells"}
+		}
+		tdOne := trChilds[1]
+		a := htmlquery.FindOne(tdOne, ".//a[@href]")
+		if a == nil {
+			return nil, &ParseError{URL: link, Element: "faction table hero link"}
+		}
+		href := htmlquery.SelectAttr(a, "href")
+		hero := DotaHeroFromLink(href)
+		tdTwo := trChilds[2]
+		tdThree := trChilds[3]
+		tdFour := trChilds[4]
+		tdFive := trChilds[5]
+		rdWinrate := htmlquery.SelectAttr(tdThree, "data-value")
+		// parse rdWinrate to float
+		rdWinrateParsed, err := strconv.ParseFloat(rdWinrate, 64)
 		if err != nil {
-
+			log.Printf("Error parsing rdWinrate: %v for hero %v", err, hero.Name)
 			return nil, err
 		}
-		return radiantDireWinrate, nil
-	} else {
-		log.Info().Msg("RadiantDireWinrate file not found, fetching from dotabuff...")
-		parsed, err := getAndParse("https://www.dotabuff.com/heroes/meta?view=played&metric=faction")
+		rdPickRate := htmlquery.SelectAttr(tdTwo, "data-value")
+		rdPickRateParsed, err := strconv.ParseFloat(rdPickRate, 64)
 		if err != nil {
+			log.Printf("Error parsing rdPickRate: %v for hero %v", err, hero.Name)
 			return nil, err
 		}
-		tbody := htmlquery.FindOne(parsed, "//section/footer/article/table/tbody")
-		tbodyChilds := ChildArray(tbody)
-		res := make([]*RadiantDireWinrate, 0)
-		for _, tr := range tbodyChilds {
-			trChilds := ChildArray(tr)
-			tdOne := trChilds[1]
-			a := ChildArray(tdOne)[0]
-			href := htmlquery.SelectAttr(a, "href")
-			hero := DotaHeroFromLink(href)
-			tdTwo := trChilds[2]
-			tdThree := trChilds[3]
-			tdFour := trChilds[4]
-			tdFive := trChilds[5]
-			rdWinrate := htmlquery.SelectAttr(tdThree, "data-value")
-			// parse rdWinrate to float
-			rdWinrateParsed, err := strconv.ParseFloat(rdWinrate, 64)
-			if err != nil {
-				log.Printf("Error parsing rdWinrate: %v for hero %v", err, hero.Name)
-				return nil, err
-			}
-			rdPickRate := htmlquery.SelectAttr(tdTwo, "data-value")
-			rdPickRateParsed, err := strconv.ParseFloat(rdPickRate, 64)
-			if err != nil {
-				log.Printf("Error parsing rdPickRate: %v for hero %v", err, hero.Name)
-				return nil, err
-			}
-			direWinrate := htmlquery.SelectAttr(tdFive, "data-value")
-			direWinrateParsed, err := strconv.ParseFloat(direWinrate, 64)
-			if err != nil {
-				log.Printf("Error parsing direWinrate: %v for hero %v", err, hero.Name)
-				return nil, err
-			}
-			direPickRate := htmlquery.SelectAttr(tdFour, "data-value")
-			direPickRateParsed, err := strconv.ParseFloat(direPickRate, 64)
-			if err != nil {
-				log.Printf("Error parsing direPickRate: %v for hero %v", err, hero.Name)
-				return nil, err
-			}
-			res = append(res, &RadiantDireWinrate{
-				Hero:            hero,
-				RadiantWinrate:  rdWinrateParsed,
-				RadiantPickRate: rdPickRateParsed,
-				DireWinrate:     direWinrateParsed,
-				DirePickRate:    direPickRateParsed,
-			})
+		direWinrate := htmlquery.SelectAttr(tdFive, "data-value")
+		direWinrateParsed, err := strconv.ParseFloat(direWinrate, 64)
+		if err != nil {
+			log.Printf("Error parsing direWinrate: %v for hero %v", err, hero.Name)
+			return nil, err
 		}
-		err = SaveRadiantDireWinrate(res)
+		direPickRate := htmlquery.SelectAttr(tdFour, "data-value")
+		direPickRateParsed, err := strconv.ParseFloat(direPickRate, 64)
 		if err != nil {
+			log.Printf("Error parsing direPickRate: %v for hero %v", err, hero.Name)
 			return nil, err
 		}
-		return res, nil
+		res = append(res, &RadiantDireWinrate{
+			Hero:            hero,
+			RadiantWinrate:  rdWinrateParsed,
+			RadiantPickRate: rdPickRateParsed,
+			DireWinrate:     direWinrateParsed,
+			DirePickRate:    direPickRateParsed,
+		})
 	}
-}
-
-func SaveRadiantDireWinrate(res []*RadiantDireWinrate) (err error) {
-	log.Info().Msg("Saving radiant dire winrate...")
-	b, err := json.Marshal(res)
-	if err != nil {
-		log.Printf("Error marshalling radiant dire winrate: %v", err)
-		return
-	}
-	err = os.WriteFile("radiant_dire_winrate.json", b, 0644)
-	if err != nil {
-		log.Printf("Error saving radiant dire winrate: %v", err)
-		return
-	}
-	return
+	return res, nil
 }
 
 func ParseRadiantDireWinrate(radiantDireWinrateJson []byte) ([]*RadiantDireWinrate, error) {
@@ -380,55 +435,31 @@ func ParseRadiantDireWinrate(radiantDireWinrateJson []byte) ([]*RadiantDireWinra
 	return res, nil
 }
 
+// Heroes fetches the list of heroes from dotabuff.
 func Heroes() ([]*Hero, error) {
-	// if file heros.json exists, return heroes from it
-	// if not, fetch heroes from dotabuff and save them to heros.json
-	heroesJson, err := os.ReadFile("heroes.json")
-	if err == nil {
-		log.Info().Msg("Heroes file found, parsing...")
-		heroes, err := ParseHeroes(heroesJson)
-		if err != nil {
-			return nil, err
-		}
-		return heroes, nil
-	} else {
-		log.Info().Msg("Heroes file not found, fetching from dotabuff...")
-		parsed, err := getAndParse("https://www.dotabuff.com/heroes")
-		if err != nil {
-			return nil, err
-		}
-		heroes := make(map[string]*Hero)
-		find := htmlquery.Find(parsed, "//table/tbody/*//a[@href]")
-		for _, n := range find {
-			href := htmlquery.SelectAttr(n, "href")
-			// strings.Starts
-			if len(href) > 8 && strings.HasPrefix(href, "/heroes/") {
-				hero := DotaHeroFromLink(href)
-				heroes[hero.Name] = hero
+	log.Info().Msg("Fetching heroes from dotabuff...")
+	parsed, err := getAndParse(DotabuffHeroesURL)
+	if err != nil {
+		return nil, err
+	}
+	heroes := make(map[int]*Hero)
+	find := htmlquery.Find(parsed, "//table/tbody/*//a[@href]")
+	for _, n := range find {
+		href := htmlquery.SelectAttr(n, "href")
+		// strings.Starts
+		if len(href) > 8 && strings.HasPrefix(href, "/heroes/") {
+			hero := DotaHeroFromLink(href)
+			if hero.ID == 0 {
+				continue
 			}
+			heroes[hero.ID] = hero
 		}
-		res := make([]*Hero, 0, len(heroes))
-		for _, hero := range heroes {
-			res = append(res, hero)
-		}
-		err = SaveHeroes(res)
-		if err != nil {
-			return nil, err
-		}
-		return res, nil
-	}
-}
-
-func SaveHeroes(heroes []*Hero) error {
-	b, err := json.Marshal(heroes)
-	if err != nil {
-		return err
 	}
-	err = os.WriteFile("heroes.json", b, 0644)
-	if err != nil {
-		return err
+	res := make([]*Hero, 0, len(heroes))
+	for _, hero := range heroes {
+		res = append(res, hero)
 	}
-	return nil
+	return res, nil
 }
 
 func ParseHeroes(b []byte) ([]*Hero, error) {
@@ -441,21 +472,20 @@ func ParseHeroes(b []byte) ([]*Hero, error) {
 }
 
 func DotaHeroFromLink(link string) *Hero {
-	name := prettyName(strings.TrimPrefix(link, "/heroes/"))
+	slug := slugFromLink(link)
+	if hero, ok := HeroBySlug(slug); ok {
+		return hero
+	}
+	log.Warn().Str("link", link).Msg("Hero is missing from the registry")
 	return &Hero{
-		Name: name,
-		Link: fmt.Sprintf("https://www.dotabuff.com%v", link),
+		Slug: slug,
+		Name: prettyName(slug),
+		Link: fmt.Sprintf("https://www.dotabuff.com/heroes/%s", slug),
 	}
 }
 
+// prettyName is only used for heroes missing from the registry.
 func prettyName(name string) string {
-	if name == "keeper-of-the-light" {
-		return "Keeper of the Light"
-	} else if name == "anti-mage" {
-		return "Anti-Mage"
-	} else if name == "queen-of-Pain" || strings.Contains(name, "queen") {
-		return "Queen of Pain"
-	} 
 	split := strings.Split(name, "-")
 	caser := cases.Title(language.English)
 	for i, s := range split {
@@ -0,0 +1,103 @@
+package dotabuff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antchfx/htmlquery"
+)
+
+const countersLink = "https://www.dotabuff.com/heroes/anti-mage/counters"
+
+func parseSavedCounters(t *testing.T, name string) ([]*Counter, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "counters", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	root, err := htmlquery.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseCountersPage(countersLink, root)
+}
+
+func parseSavedMatch(t *testing.T, name string) (*DotabuffMatch, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "matches", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	root, err := htmlquery.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseMatchPage("https://www.dotabuff.com/matches/7000000001", root)
+}
+
+func TestParseMatchPage(t *testing.T) {
+	match, err := parseSavedMatch(t, "radiant_win.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match.RadiantWon {
+		t.Fatal("radiant did not win")
+	}
+	if match.RadiantTeam.Name != "Tundra Esports" || match.RadiantTeam.Link != "https://www.dotabuff.com/esports/teams/8291895-tundra-esports" {
+		t.Fatalf("got radiant team %+v", match.RadiantTeam)
+	}
+	if match.DireTeam.Name != "Team Liquid" {
+		t.Fatalf("got dire team %+v", match.DireTeam)
+	}
+}
+
+func TestParseMatchPageWithoutTeam(t *testing.T) {
+	match, err := parseSavedMatch(t, "dire_win_without_team.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match.RadiantWon {
+		t.Fatal("radiant won")
+	}
+	if match.RadiantTeam.Name != "Radiant" || match.RadiantTeam.Link != "" {
+		t.Fatalf("got radiant team %+v", match.RadiantTeam)
+	}
+	if match.DireTeam.Name != "Team Liquid" {
+		t.Fatalf("got dire team %+v", match.DireTeam)
+	}
+}
+
+func TestParseMatchPageChangedMarkup(t *testing.T) {
+	for _, name := range []string{"renamed_winner_marker.html", "missing_player_row.html", "renamed_sections.html"} {
+		_, err := parseSavedMatch(t, name)
+		if !IsLayoutChanged(err) {
+			t.Errorf("%s: got %v, want a ParseError", name, err)
+		}
+	}
+}
+
+func TestParseCountersPage(t *testing.T) {
+	counters, err := parseSavedCounters(t, "anti_mage.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counters) != 3 {
+		t.Fatalf("got %d counters, want 3", len(counters))
+	}
+	axe := counters[0]
+	if axe.Hero.ID != 2 || axe.Disadvantage != 2.5 || axe.WinRate != 55.1 || axe.MatchesPlayed != 123456 {
+		t.Fatalf("got %+v", axe)
+	}
+}
+
+func TestParseCountersPageChangedMarkup(t *testing.T) {
+	for _, name := range []string{"renamed_values.html", "missing_hero_link.html", "merged_cells.html"} {
+		_, err := parseSavedCounters(t, name)
+		if !IsLayoutChanged(err) {
+			t.Errorf("%s: got %v, want a ParseError", name, err)
+		}
+	}
+}
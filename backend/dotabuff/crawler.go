@@ -0,0 +1,186 @@
+package dotabuff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/rs/zerolog/log"
+)
+
+// leagueMaxPages bounds the pagination of the dotabuff league matches.
+const leagueMaxPages = 200
+
+var leagueIDRe = regexp.MustCompile(`^(?:(?:https?://)?(?:www\.)?dotabuff\.com)?/?esports/leagues/(\d+)`)
+
+// ParseLeagueID accepts a league ID or a dotabuff league link such as
+// "https://www.dotabuff.com/esports/leagues/15728-the-international-2023/matches".
+func ParseLeagueID(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil && id > 0 {
+		return id, nil
+	}
+	m := leagueIDRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("Invalid league: %q", s)
+	}
+	return strconv.ParseInt(m[1], 10, 64)
+}
+
+// DotabuffLeagueMatches lists the match IDs of a league from its paginated
+// dotabuff matches page.
+func DotabuffLeagueMatches(leagueID int64) ([]int64, error) {
+	seen := make(map[int64]bool)
+	res := make([]int64, 0)
+	for page := 1; page <= leagueMaxPages; page++ {
+		link := fmt.Sprintf("https://www.dotabuff.com/esports/leagues/%d/matches?page=%d", leagueID, page)
+		parsed, err := getAndParse(link)
+		if IsNotFound(err) && page > 1 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		found := 0
+		for _, a := range htmlquery.Find(parsed, "//table//a[starts-with(@href, '/matches/')]") {
+			id, err := MatchIDFromDBLink("https://www.dotabuff.com" + htmlquery.SelectAttr(a, "href"))
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			res = append(res, id)
+			found++
+		}
+		if found == 0 {
+			break
+		}
+	}
+	if len(res) == 0 {
+		return nil, &ParseError{URL: fmt.Sprintf("https://www.dotabuff.com/esports/leagues/%d/matches", leagueID), Element: "match links"}
+	}
+	return res, nil
+}
+
+// Crawler stores every match of a league. The IDs of the stored matches
+// are kept in StateDir so an interrupted crawl continues where it stopped.
+type Crawler struct {
+	Engine   *Engine
+	StateDir string
+	Options  PickOptions
+}
+
+type CrawlStats struct {
+	Matches int
+	Stored  int
+	Skipped int
+	Failed  int
+}
+
+type crawlState struct {
+	LeagueID int64   `json:"league_id"`
+	Done     []int64 `json:"done"`
+}
+
+func NewCrawler(engine *Engine, stateDir string) *Crawler {
+	return &Crawler{
+		Engine:   engine,
+		StateDir: stateDir,
+	}
+}
+
+func (c *Crawler) statePath(leagueID int64) string {
+	return filepath.Join(c.StateDir, fmt.Sprintf("league-%d.json", leagueID))
+}
+
+func (c *Crawler) loadState(leagueID int64) (*crawlState, error) {
+	state := &crawlState{LeagueID: leagueID}
+	b, err := os.ReadFile(c.statePath(leagueID))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, state)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding crawl state of league %d: %v", leagueID, err)
+	}
+	return state, nil
+}
+
+func (c *Crawler) saveState(state *crawlState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.StateDir, 0755)
+	if err != nil {
+		return err
+	}
+	path := c.statePath(state.LeagueID)
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// CrawlLeague fetches, scores and stores every match of the league that
+// was not stored by a previous crawl. A match that fails is logged and
+// retried by the next crawl, a dotabuff layout change stops the crawl.
+// Without MySQL nothing could be stored, so nothing is fetched.
+func (c *Crawler) CrawlLeague(leagueID int64) (*CrawlStats, error) {
+	if c.Engine.mysql == nil {
+		return nil, fmt.Errorf("Crawling a league stores its matches and needs MySQL")
+	}
+	state, err := c.loadState(leagueID)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int64]bool, len(state.Done))
+	for _, id := range state.Done {
+		done[id] = true
+	}
+	ids, err := c.Engine.LeagueMatches(leagueID)
+	if err != nil {
+		return nil, err
+	}
+	stats := &CrawlStats{Matches: len(ids)}
+	log.Info().Int64("league", leagueID).Int("matches", len(ids)).Int("done", len(done)).Msg("Crawling league")
+	tick := time.Now()
+	for _, id := range ids {
+		if done[id] {
+			stats.Skipped++
+			continue
+		}
+		match, err := c.Engine.Match(id)
+		if err == nil {
+			_, err = c.Engine.StoreMatch(match, c.Options)
+		}
+		if IsLayoutChanged(err) {
+			return stats, err
+		}
+		if err != nil {
+			log.Error().Err(err).Int64("match", id).Msg("Error crawling match")
+			stats.Failed++
+			continue
+		}
+		stats.Stored++
+		state.Done = append(state.Done, id)
+		done[id] = true
+		err = c.saveState(state)
+		if err != nil {
+			return stats, err
+		}
+	}
+	log.Info().Int64("league", leagueID).Int("stored", stats.Stored).Int("skipped", stats.Skipped).Int("failed", stats.Failed).
+		Msgf("League has been crawled in %0.2f seconds", time.Since(tick).Seconds())
+	return stats, nil
+}
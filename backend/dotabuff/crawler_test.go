@@ -0,0 +1,50 @@
+package dotabuff
+
+import "testing"
+
+// countingSource counts the league and match fetches of a fixture source.
+type countingSource struct {
+	*FixtureSource
+	fetches int
+}
+
+func (s *countingSource) LeagueMatches(leagueID int64) ([]int64, error) {
+	s.fetches++
+	return s.FixtureSource.LeagueMatches(leagueID)
+}
+
+func (s *countingSource) Match(id int64) (*DotabuffMatch, error) {
+	s.fetches++
+	return s.FixtureSource.Match(id)
+}
+
+func TestFixtureLeagueMatches(t *testing.T) {
+	src := NewFixtureSource(nil, nil, nil)
+	for i, link := range []string{
+		"https://www.dotabuff.com/esports/leagues/16935-the-international-2024",
+		"https://www.dotabuff.com/esports/leagues/16935",
+		"https://www.dotabuff.com/esports/leagues/15728-the-international-2023",
+	} {
+		src.AddMatch(&DotabuffMatch{MatchID: int64(7000000003 - i), TournamentLink: link})
+	}
+	ids, err := src.LeagueMatches(16935)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 7000000002 || ids[1] != 7000000003 {
+		t.Fatalf("got %v", ids)
+	}
+}
+
+func TestCrawlLeagueWithoutMySQL(t *testing.T) {
+	src := &countingSource{FixtureSource: NewFixtureSource(nil, nil, nil)}
+	src.AddMatch(&DotabuffMatch{MatchID: 7000000001, TournamentLink: "https://www.dotabuff.com/esports/leagues/16935"})
+	c := NewCrawler(newFixtureEngine(t, src), t.TempDir())
+	stats, err := c.CrawlLeague(16935)
+	if err == nil {
+		t.Fatalf("crawl without MySQL did not fail: %+v", stats)
+	}
+	if src.fetches != 0 {
+		t.Fatalf("got %d fetches before failing", src.fetches)
+	}
+}
@@ -0,0 +1,186 @@
+package dotabuff
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DataSource provides everything the engine needs to build its model:
+// the current patch, the hero list, per-side winrates and the counters of
+// every hero, as well as the drafts of played matches.
+type DataSource interface {
+	Patch() (string, error)
+	Heroes() ([]*Hero, error)
+	SideWinrates() ([]*RadiantDireWinrate, error)
+	Counters(hero *Hero, filter CounterFilter) ([]*Counter, error)
+	Match(id int64) (*DotabuffMatch, error)
+	LeagueMatches(leagueID int64) ([]int64, error)
+	Team(id int64) (*TeamProfile, error)
+}
+
+// SourceURLs is implemented by the sources that can tell where their data
+// comes from, the URLs are kept in the cache metadata.
+type SourceURLs interface {
+	HeroesURL() string
+	SideWinratesURL() string
+	CountersURL(hero *Hero, filter CounterFilter) string
+}
+
+// PatchDater is implemented by the sources that know the release dates of
+// the patches.
+type PatchDater interface {
+	PatchDates() (map[string]time.Time, error)
+}
+
+// NewDataSource returns the data source registered under the given name.
+func NewDataSource(name string) (DataSource, error) {
+	switch name {
+	case "", "dotabuff":
+		return NewDotabuffSource(), nil
+	case "opendota":
+		return NewOpenDotaSource(OpenDotaBaseURL), nil
+	default:
+		return nil, fmt.Errorf("Unknown data source: %s", name)
+	}
+}
+
+// DotabuffSource scrapes dotabuff.com.
+type DotabuffSource struct{}
+
+func NewDotabuffSource() *DotabuffSource {
+	return &DotabuffSource{}
+}
+
+// Patch asks OpenDota for the current patch, dotabuff has no page listing it.
+func (d *DotabuffSource) Patch() (string, error) {
+	return LatestPatch(DefaultFetcher, OpenDotaBaseURL)
+}
+
+// PatchDates asks OpenDota too.
+func (d *DotabuffSource) PatchDates() (map[string]time.Time, error) {
+	return PatchDates(DefaultFetcher, OpenDotaBaseURL)
+}
+
+func (d *DotabuffSource) HeroesURL() string {
+	return DotabuffHeroesURL
+}
+
+func (d *DotabuffSource) SideWinratesURL() string {
+	return DotabuffSideWinratesURL
+}
+
+func (d *DotabuffSource) CountersURL(hero *Hero, filter CounterFilter) string {
+	return hero.CountersURL(filter)
+}
+
+func (d *DotabuffSource) Heroes() ([]*Hero, error) {
+	return Heroes()
+}
+
+func (d *DotabuffSource) SideWinrates() ([]*RadiantDireWinrate, error) {
+	return RaidantAndDireWR()
+}
+
+func (d *DotabuffSource) Counters(hero *Hero, filter CounterFilter) ([]*Counter, error) {
+	return hero.CountersWithFilter(filter)
+}
+
+func (d *DotabuffSource) Match(id int64) (*DotabuffMatch, error) {
+	return ExtractHerosFromDBMatch(id)
+}
+
+func (d *DotabuffSource) LeagueMatches(leagueID int64) ([]int64, error) {
+	return DotabuffLeagueMatches(leagueID)
+}
+
+func (d *DotabuffSource) Team(id int64) (*TeamProfile, error) {
+	return DotabuffTeam(id)
+}
+
+// FixtureSource serves data kept in memory, counters are keyed by hero ID.
+// It never touches the network and is meant for tests and local experiments.
+type FixtureSource struct {
+	patch    string
+	heroes   []*Hero
+	sideWR   []*RadiantDireWinrate
+	counters map[int][]*Counter
+	matches  map[int64]*DotabuffMatch
+	teams    map[int64]*TeamProfile
+}
+
+func NewFixtureSource(heroes []*Hero, sideWR []*RadiantDireWinrate, counters map[int][]*Counter) *FixtureSource {
+	if counters == nil {
+		counters = make(map[int][]*Counter)
+	}
+	return &FixtureSource{
+		patch:    "fixture",
+		heroes:   heroes,
+		sideWR:   sideWR,
+		counters: counters,
+		matches:  make(map[int64]*DotabuffMatch),
+		teams:    make(map[int64]*TeamProfile),
+	}
+}
+
+func (f *FixtureSource) AddMatch(match *DotabuffMatch) {
+	f.matches[match.MatchID] = match
+}
+
+func (f *FixtureSource) AddTeam(team *TeamProfile) {
+	f.teams[team.ID] = team
+}
+
+func (f *FixtureSource) SetPatch(patch string) {
+	f.patch = patch
+}
+
+func (f *FixtureSource) Patch() (string, error) {
+	return f.patch, nil
+}
+
+func (f *FixtureSource) Heroes() ([]*Hero, error) {
+	return f.heroes, nil
+}
+
+func (f *FixtureSource) SideWinrates() ([]*RadiantDireWinrate, error) {
+	return f.sideWR, nil
+}
+
+// Counters ignores the filter, the fixture has a single set of counters.
+func (f *FixtureSource) Counters(hero *Hero, filter CounterFilter) ([]*Counter, error) {
+	counters, ok := f.counters[hero.ID]
+	if !ok {
+		return nil, fmt.Errorf("No counters for %s in fixture", hero.Name)
+	}
+	return counters, nil
+}
+
+func (f *FixtureSource) Match(id int64) (*DotabuffMatch, error) {
+	match, ok := f.matches[id]
+	if !ok {
+		return nil, fmt.Errorf("No match %d in fixture", id)
+	}
+	return match, nil
+}
+
+// LeagueMatches returns the fixture matches whose tournament link points
+// to the league.
+func (f *FixtureSource) LeagueMatches(leagueID int64) ([]int64, error) {
+	res := make([]int64, 0)
+	for id, match := range f.matches {
+		if l, err := ParseLeagueID(match.TournamentLink); err == nil && l == leagueID {
+			res = append(res, id)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res, nil
+}
+
+func (f *FixtureSource) Team(id int64) (*TeamProfile, error) {
+	team, ok := f.teams[id]
+	if !ok {
+		return nil, fmt.Errorf("No team %d in fixture", id)
+	}
+	return team, nil
+}
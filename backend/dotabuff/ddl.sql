@@ -1,8 +1,11 @@
+-- Schema of a new database, upgrade an existing one with migrate.sql.
 create table dotabuff_match
 (
     id                     bigint,
     dire_heroes            text,
     radiant_heroes         text,
+    dire_hero_ids          text,
+    radiant_hero_ids       text,
     radiant_won            boolean,
     radiant_won_prediction boolean,
     tournament_link        text,
@@ -10,6 +13,103 @@ create table dotabuff_match
     radiant_team_link      text,
     radiant_win_prediction float,
     dire_win_prediction    float,
+    raw_radiant_win_prediction float,
+    raw_dire_win_prediction    float,
     algorithm_version      varchar(255),
+    patch                  varchar(32),
+    counter_filter         varchar(64),
+    start_time             datetime,
+    duration_seconds       int,
+    game_mode              varchar(64),
+    lobby_type             varchar(64),
+    region                 varchar(64),
+    predicted_at           datetime,
     PRIMARY KEY (id, algorithm_version)
-);
\ No newline at end of file
+);
+
+create table dotabuff_match_player
+(
+    match_id    bigint,
+    hero_id     int,
+    account_id  bigint,
+    player_name text,
+    is_radiant  boolean,
+    kills       int,
+    deaths      int,
+    assists     int,
+    net_worth   int,
+    level       int,
+    items       text,
+    lane        varchar(16),
+    position    int,
+    PRIMARY KEY (match_id, hero_id)
+);
+create table dotabuff_match_draft
+(
+    match_id    bigint,
+    draft_order int,
+    is_pick     boolean,
+    is_radiant  boolean,
+    hero_id     int,
+    PRIMARY KEY (match_id, draft_order)
+);
+
+create table team
+(
+    id         bigint,
+    name       text,
+    link       text,
+    fetched_at datetime,
+    PRIMARY KEY (id)
+);
+
+create table team_player
+(
+    team_id    bigint,
+    account_id bigint,
+    name       text,
+    PRIMARY KEY (team_id, account_id)
+);
+
+create table team_hero
+(
+    team_id  bigint,
+    hero_id  int,
+    matches  int,
+    win_rate float,
+    PRIMARY KEY (team_id, hero_id)
+);
+
+create table team_result
+(
+    team_id  bigint,
+    match_id bigint,
+    won      boolean,
+    opponent text,
+    PRIMARY KEY (team_id, match_id)
+);
+
+create table live_prediction
+(
+    match_id               bigint,
+    algorithm_version      varchar(255),
+    league_id              bigint,
+    radiant_team           text,
+    dire_team              text,
+    radiant_hero_ids       text,
+    dire_hero_ids          text,
+    radiant_win_prediction float,
+    dire_win_prediction    float,
+    patch                  varchar(32),
+    counter_filter         varchar(64),
+    predicted_at           datetime,
+    PRIMARY KEY (match_id, algorithm_version)
+);
+
+create table cache_entry
+(
+    cache_key  varchar(255),
+    fetched_at datetime,
+    entry      longblob,
+    PRIMARY KEY (cache_key)
+);
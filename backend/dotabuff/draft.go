@@ -0,0 +1,106 @@
+package dotabuff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+)
+
+// DraftAction is a single pick or ban of a captain's mode draft, Order
+// starts at 1.
+type DraftAction struct {
+	Order   int
+	Pick    bool
+	Radiant bool
+	Hero    *Hero
+}
+
+// Selectors of the pick/ban list in the side sections of a match page.
+// Every entry is a pick or a ban holding a hero link and its sequence
+// number.
+const (
+	draftEntryXPath = ".//div[contains(concat(' ', normalize-space(@class), ' '), ' pick ') or contains(concat(' ', normalize-space(@class), ' '), ' ban ')]"
+	draftSeqXPath   = ".//*[contains(concat(' ', normalize-space(@class), ' '), ' seq ')]"
+)
+
+// ParseDraft returns the pick/ban order of a dotabuff match page sorted
+// by Order. Matches without a captain's mode draft have none.
+func ParseDraft(link string, root *html.Node) ([]*DraftAction, error) {
+	draft := make([]*DraftAction, 0, 24)
+	for _, side := range []string{"radiant", "dire"} {
+		section := sideSection(root, side)
+		if section == nil {
+			continue
+		}
+		for _, n := range htmlquery.Find(section, draftEntryXPath) {
+			a := htmlquery.FindOne(n, ".//a[starts-with(@href, '/heroes/')]")
+			seq := htmlquery.FindOne(n, draftSeqXPath)
+			if a == nil || seq == nil {
+				return nil, &ParseError{URL: link, Element: side + " draft entry"}
+			}
+			order, err := strconv.Atoi(strings.TrimSpace(htmlquery.InnerText(seq)))
+			if err != nil {
+				return nil, &ParseError{URL: link, Element: side + " draft sequence number"}
+			}
+			draft = append(draft, &DraftAction{
+				Order:   order,
+				Pick:    strings.Contains(" "+htmlquery.SelectAttr(n, "class")+" ", " pick "),
+				Radiant: side == "radiant",
+				Hero:    DotaHeroFromLink(htmlquery.SelectAttr(a, "href")),
+			})
+		}
+	}
+	sort.Slice(draft, func(i, j int) bool { return draft[i].Order < draft[j].Order })
+	return draft, nil
+}
+
+// DraftStep is the win probability once the action has been made.
+type DraftStep struct {
+	Action     *DraftAction
+	RadiantWin float64
+	DireWin    float64
+}
+
+// DraftWinRates scores the draft of the match after every pick with the
+// predictor of opts.Algorithm. Bans do not change the heroes on the board
+// and repeat the previous score, which is 50/50 until both sides picked.
+func (e *Engine) DraftWinRates(match *DotabuffMatch, opts PickOptions) ([]*DraftStep, error) {
+	if !e.Loaded() {
+		return nil, fmt.Errorf("Data has not been loaded yet. Please try again in like 30 seconds")
+	}
+	if len(match.Draft) == 0 {
+		return nil, fmt.Errorf("Match %d has no pick/ban order", match.MatchID)
+	}
+	p, err := PredictorFor(opts.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := e.DatasetFor(opts)
+	if err != nil {
+		return nil, err
+	}
+	radiant := make([]*Hero, 0, 5)
+	dire := make([]*Hero, 0, 5)
+	steps := make([]*DraftStep, 0, len(match.Draft))
+	for _, action := range match.Draft {
+		if action.Pick && action.Radiant {
+			radiant = append(radiant, action.Hero)
+		} else if action.Pick {
+			dire = append(dire, action.Hero)
+		}
+		err = ds.CheckCounters(radiant, dire)
+		if err != nil {
+			return nil, err
+		}
+		rw, dw := 50.0, 50.0
+		if len(radiant) > 0 && len(dire) > 0 {
+			rw, dw = p.WinRate(ds, radiant, dire)
+		}
+		steps = append(steps, &DraftStep{Action: action, RadiantWin: rw, DireWin: dw})
+	}
+	return steps, nil
+}
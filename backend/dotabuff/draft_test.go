@@ -0,0 +1,59 @@
+package dotabuff
+
+import "testing"
+
+// fixtureDraft picks heroes 0 and 5, bans hero 9, then picks 1 and 6.
+func fixtureDraft(heroes []*Hero) *DotabuffMatch {
+	return &DotabuffMatch{MatchID: 1, Draft: []*DraftAction{
+		{Order: 1, Pick: true, Radiant: true, Hero: heroes[0]},
+		{Order: 2, Pick: true, Hero: heroes[5]},
+		{Order: 3, Radiant: true, Hero: heroes[9]},
+		{Order: 4, Pick: true, Radiant: true, Hero: heroes[1]},
+		{Order: 5, Pick: true, Hero: heroes[6]},
+	}}
+}
+
+func TestDraftWinRates(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, fixtureCounters(heroes)))
+	if err := e.LoadHeroes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.LoadCounters(); err != nil {
+		t.Fatal(err)
+	}
+	match := fixtureDraft(heroes)
+	steps, err := e.DraftWinRates(match, PickOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 5 || steps[0].RadiantWin != 50 {
+		t.Fatalf("got %d steps starting at %v", len(steps), steps[0].RadiantWin)
+	}
+	// heroes 0 against 5: radiant gets 50+(0-5)
+	if !approxEqual(steps[1].RadiantWin, 45) || steps[2].RadiantWin != steps[1].RadiantWin {
+		t.Fatalf("got %v then %v after the ban", steps[1].RadiantWin, steps[2].RadiantWin)
+	}
+	last := steps[4]
+	rw, dw := e.PickWinRate(heroes[:2], heroes[5:7])
+	if !approxEqual(last.RadiantWin, rw) || !approxEqual(last.DireWin, dw) {
+		t.Fatalf("got %v/%v, PickWinRate %v/%v", last.RadiantWin, last.DireWin, rw, dw)
+	}
+
+	steps, err = e.DraftWinRates(match, PickOptions{Algorithm: AlgorithmLogOdds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw, dw = DefaultLogOddsModel.WinRate(e.Dataset, heroes[:2], heroes[5:7])
+	if !approxEqual(steps[4].RadiantWin, rw) || !approxEqual(steps[4].DireWin, dw) {
+		t.Fatalf("got %v/%v, log-odds %v/%v", steps[4].RadiantWin, steps[4].DireWin, rw, dw)
+	}
+	if approxEqual(steps[4].RadiantWin, last.RadiantWin) {
+		t.Fatal("the algorithm has been ignored")
+	}
+
+	_, err = e.DraftWinRates(match, PickOptions{Algorithm: "v0"})
+	if err == nil {
+		t.Fatal("unknown algorithm did not fail")
+	}
+}
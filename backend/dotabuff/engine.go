@@ -8,83 +8,272 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
-type Engine struct {
+// Dataset is everything the engine knows about a single patch. The
+// counters of a patch dataset come from the default view, datasets for
+// other counter filters share its heroes and side winrates.
+// A dataset handed out by the engine is never modified, a reload builds a
+// new one and swaps it in under the engine lock, so readers need no lock.
+type Dataset struct {
+	Patch  string
+	Filter CounterFilter
+	// Table is the counter table, empty for the dotabuff counters
+	Table string
+
 	// original fields
 	Heroes   []*Hero
-	Counters map[string][]*Counter
+	Counters map[int][]*Counter
 	SideWR   []*RadiantDireWinrate
 
-	// aggregated fields
+	// aggregated fields, keyed by hero ID
+	CountersMap map[int]map[int]*Counter
+	HeroSideWR  map[int]*RadiantDireWinrate
+
+	countersLoaded bool
+	layoutErr      error
+	filtered       map[string]*Dataset
+}
+
+func NewDataset(patch string) *Dataset {
+	return &Dataset{
+		Patch:       patch,
+		filtered:    make(map[string]*Dataset),
+		Heroes:      make([]*Hero, 0),
+		SideWR:      make([]*RadiantDireWinrate, 0),
+		Counters:    make(map[int][]*Counter),
+		CountersMap: make(map[int]map[int]*Counter),
+		HeroSideWR:  make(map[int]*RadiantDireWinrate),
+	}
+}
+
+type Engine struct {
+	// dataset of the current patch
+	*Dataset
+
 	HeroShortNames map[string]*Hero
-	CountersMap    map[string]map[string]*Counter
-	HeroSideWR     map[string]*RadiantDireWinrate
+
+	// Concurrency is the number of heroes whose counters are fetched in parallel
+	Concurrency int
+
+	// Shadow stores the predictions of every registered predictor along
+	// with the requested one, it is on by default so that every version
+	// gets evaluated on the same matches
+	Shadow bool
 
 	// internal fields
-	lock  sync.Mutex
-	mysql *MySQL
+	lock sync.Mutex
+	// loaded tells whether the current dataset has counters, it is kept
+	// next to the lock so that readiness checks never wait on a reload
+	loaded   atomic.Bool
+	mysql    *MySQL
+	source   DataSource
+	store    *PatchStore
+	datasets map[string]*Dataset
+
+	proDatasets map[string]*proDataset
+	teams       map[int64]*cachedTeam
+	// inserts tracks the matches being stored in the background
+	inserts sync.WaitGroup
 }
 
-func NewEngine(mysql *MySQL) *Engine {
+func NewEngine(mysql *MySQL, source DataSource) *Engine {
 	return &Engine{
-		Heroes:         make([]*Hero, 0),
+		Dataset:        NewDataset(""),
 		HeroShortNames: make(map[string]*Hero),
-		SideWR:         make([]*RadiantDireWinrate, 0),
-		Counters:       make(map[string][]*Counter),
-		CountersMap:    make(map[string]map[string]*Counter),
-		HeroSideWR:     make(map[string]*RadiantDireWinrate),
 		lock:           sync.Mutex{},
 		mysql:          mysql,
+		source:         source,
+		store:          NewPatchStore(NewFileCache("data")),
+		datasets:       make(map[string]*Dataset),
+		proDatasets:    make(map[string]*proDataset),
+		teams:          make(map[int64]*cachedTeam),
+		Concurrency:    4,
+		Shadow:         true,
 	}
 }
 
+// Loaded tells whether the counters of the current patch are available,
+// it does not take the lock.
 func (s *Engine) Loaded() bool {
-	status := s.lock.TryLock()
-	if status {
-		defer s.lock.Unlock()
-	}
-	return status
+	return s.loaded.Load()
 }
 
+// setDataset makes ds the dataset of the current patch, the caller holds
+// the lock.
+func (e *Engine) setDataset(ds *Dataset) {
+	e.Dataset = ds
+	e.datasets[ds.Patch] = ds
+	e.loaded.Store(ds.countersLoaded)
+}
+
+// LoadHeroes detects the current patch and loads the heroes and the side
+// winrates of it, from the patch store when possible. A new patch gets a
+// fresh dataset, so nothing cached for the previous patch is reused.
 func (s *Engine) LoadHeroes() error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	log.Info().Msg("Loading heroes...")
-	heroes, err := Heroes()
+	patch, err := s.source.Patch()
 	if err != nil {
-		return err
+		log.Warn().Err(err).Msg("Error detecting current patch")
+		patch = s.Patch
+		if patch == "" {
+			patch = UnknownPatch
+		}
+	}
+	ds := s.Dataset.copy()
+	if patch != ds.Patch {
+		if ds.Patch != "" {
+			log.Info().Str("old", ds.Patch).Str("new", patch).Msg("New patch detected")
+		}
+		ds = NewDataset(patch)
+	}
+	log.Info().Str("patch", patch).Msg("Loading heroes...")
+	sourceHeroes, ok, err := s.store.LoadHeroes(patch)
+	if err != nil || !ok {
+		sourceHeroes, err = s.source.Heroes()
+		if err != nil {
+			return err
+		}
+		err = s.store.SaveHeroes(patch, s.sourceURL(func(u SourceURLs) string { return u.HeroesURL() }), sourceHeroes)
+		if err != nil {
+			log.Error().Err(err).Msg("Error saving heroes")
+		}
+	}
+	heroes := make([]*Hero, 0, len(sourceHeroes))
+	for _, h := range sourceHeroes {
+		hero := CanonicalHero(h)
+		if hero.ID == 0 {
+			log.Warn().Str("hero", h.Name).Msg("Skipping hero missing from the registry")
+			continue
+		}
+		heroes = append(heroes, hero)
 	}
 	log.Info().
 		Int("count", len(heroes)).
 		Msg("Heroes has been loaded")
-	s.Heroes = heroes
-	for _, hero := range heroes {
-		s.HeroShortNames[hero.Name] = hero
-		s.HeroShortNames[strings.ToLower(hero.Name)] = hero
-		addShortNames(&s.HeroShortNames, hero)
-	}
+	ds.Heroes = heroes
+	s.addHeroNames(heroes)
 	log.Info().Msg("Loading radiant and dire winrates...")
-	wrs, err := RaidantAndDireWR()
-	if err != nil {
-		log.Error().Err(err).Msg("Error loading radiant and dire winrates")
-		return err
+	wrs, ok, err := s.store.LoadSideWR(patch, true)
+	if err != nil || !ok {
+		wrs, err = s.source.SideWinrates()
+		if err != nil {
+			log.Error().Err(err).Msg("Error loading radiant and dire winrates")
+			return err
+		}
+		err = s.store.SaveSideWR(patch, s.sourceURL(func(u SourceURLs) string { return u.SideWinratesURL() }), wrs)
+		if err != nil {
+			log.Error().Err(err).Msg("Error saving radiant and dire winrates")
+		}
 	}
-	s.SideWR = wrs
-	for _, wr := range wrs {
-		s.HeroSideWR[wr.Hero.Name] = wr
+	ds.SetSideWR(wrs)
+	if !ds.HasSideSplit() {
+		log.Warn().Str("patch", patch).Msg("Winrates are not split by side, predictions get no radiant edge")
 	}
 	log.Info().Msg("Radiant and dire winrates has been loaded")
+	s.setDataset(ds)
 	return nil
 }
 
+// SetSideWR replaces the side winrates of the dataset with copies pointing
+// to the registry heroes, wrs may belong to a published dataset.
+func (d *Dataset) SetSideWR(wrs []*RadiantDireWinrate) {
+	sideWR := make([]*RadiantDireWinrate, 0, len(wrs))
+	heroSideWR := make(map[int]*RadiantDireWinrate, len(wrs))
+	for _, wr := range wrs {
+		c := *wr
+		c.Hero = CanonicalHero(wr.Hero)
+		sideWR = append(sideWR, &c)
+		heroSideWR[c.Hero.ID] = &c
+	}
+	d.SideWR = sideWR
+	d.HeroSideWR = heroSideWR
+}
+
+// withFilter returns a dataset sharing the heroes and side winrates of d,
+// its counters are still to be loaded.
+func (d *Dataset) withFilter(filter CounterFilter) *Dataset {
+	ds := NewDataset(d.Patch)
+	ds.Filter = filter
+	ds.Heroes = d.Heroes
+	ds.SideWR = d.SideWR
+	ds.HeroSideWR = d.HeroSideWR
+	return ds
+}
+
+// copy returns a shallow copy of the dataset to be modified and swapped in,
+// the copy shares the filtered datasets.
+func (d *Dataset) copy() *Dataset {
+	c := *d
+	return &c
+}
+
+// withCounters returns a copy of the dataset with the counters replaced.
+func (d *Dataset) withCounters(counters map[int][]*Counter, layoutErr error) *Dataset {
+	c := d.copy()
+	c.SetCounters(counters)
+	c.layoutErr = layoutErr
+	return c
+}
+
+// swapDataset replaces old with ds wherever the engine references it, the
+// caller holds the lock.
+func (e *Engine) swapDataset(old, ds *Dataset) {
+	if e.Dataset == old {
+		e.setDataset(ds)
+	} else if e.datasets[old.Patch] == old {
+		e.datasets[old.Patch] = ds
+	}
+	// filtered maps are shared by the copies of a dataset, so visiting the
+	// current ones is enough
+	bases := []*Dataset{e.Dataset}
+	for _, base := range e.datasets {
+		bases = append(bases, base)
+	}
+	for _, base := range bases {
+		for key, f := range base.filtered {
+			if f == old {
+				base.filtered[key] = ds
+			}
+		}
+	}
+}
+
+// SetCounters replaces the counters of the dataset, counters are keyed by
+// the ID of the hero whose counters page they come from. It is meant for
+// datasets under construction, see withCounters. The dataset keeps copies
+// pointing to the registry heroes, as counters may be shared with a
+// published dataset.
+func (d *Dataset) SetCounters(counters map[int][]*Counter) {
+	copies := make(map[int][]*Counter, len(counters))
+	countersMap := make(map[int]map[int]*Counter)
+	for heroID, heroCounters := range counters {
+		heroCopies := make([]*Counter, 0, len(heroCounters))
+		for _, counter := range heroCounters {
+			c := *counter
+			c.Hero = CanonicalHero(counter.Hero)
+			heroCopies = append(heroCopies, &c)
+			if _, ok := countersMap[c.Hero.ID]; !ok {
+				countersMap[c.Hero.ID] = make(map[int]*Counter, 0)
+			}
+			countersMap[c.Hero.ID][heroID] = &c
+		}
+		copies[heroID] = heroCopies
+	}
+	d.Counters = copies
+	d.CountersMap = countersMap
+	d.countersLoaded = true
+}
+
 func (e *Engine) SideMultiplier(hero *Hero, radiant bool) float64 {
 	return 1
-	// wr := e.HeroSideWR[hero.Name]
+	// wr := e.HeroSideWR[hero.ID]
 	// var multiplier float64
 	// if radiant {
 	// 	multiplier = wr.RadiantWinrate / wr.DireWinrate
@@ -111,6 +300,18 @@ func (e *Engine) FindHeroes(names []string) ([]*Hero, error) {
 	return heroes, nil
 }
 
+// addHeroNames registers the names a hero can be found by.
+func (s *Engine) addHeroNames(heroes []*Hero) {
+	for _, hero := range heroes {
+		s.HeroShortNames[hero.Name] = hero
+		s.HeroShortNames[strings.ToLower(hero.Name)] = hero
+		s.HeroShortNames[hero.Slug] = hero
+		s.HeroShortNames[strings.ReplaceAll(hero.Slug, "-", " ")] = hero
+		s.HeroShortNames[strings.TrimPrefix(hero.NpcName, "npc_dota_hero_")] = hero
+		addShortNames(&s.HeroShortNames, hero)
+	}
+}
+
 func addShortNames(mp *map[string]*Hero, hero *Hero) {
 	addArr := func(arrWords []string) {
 		firstLettersArr := make([]string, 0)
@@ -136,61 +337,386 @@ func addShortNames(mp *map[string]*Hero, hero *Hero) {
 	}
 }
 
+// LoadCounters fetches the counters of every hero of the current patch
+// using Concurrency workers, then refreshes the counters of the filters
+// already in use. The engine keeps serving the previous counters until all
+// of them have been fetched, and keeps them for the heroes whose fetch
+// fails. The first error is returned once every dataset has been tried.
 func (e *Engine) LoadCounters() error {
 	e.lock.Lock()
-	defer e.lock.Unlock()
+	ds := e.Dataset
+	e.lock.Unlock()
+	firstErr := e.loadDatasetCounters(ds)
+	e.lock.Lock()
+	filtered := make([]*Dataset, 0, len(ds.filtered))
+	for _, f := range ds.filtered {
+		filtered = append(filtered, f)
+	}
+	e.lock.Unlock()
+	for _, f := range filtered {
+		err := e.loadDatasetCounters(f)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// loadDatasetCounters refreshes the counters of the dataset. A hero whose
+// counters could not be fetched keeps the ones it had, and the dataset is
+// left unloaded when nothing at all is available.
+func (e *Engine) loadDatasetCounters(ds *Dataset) error {
 	tick := time.Now()
-	for i, hero := range e.Heroes {
-		counters, err := hero.Counters()
-		if err != nil {
-			log.Printf("Error fetching counters for %s: %v", hero.Name, err)
+	fetched, layoutErr := e.fetchCounters(ds.Patch, ds.Filter, ds.Heroes)
+	counters := make(map[int][]*Counter, len(fetched))
+	failed := 0
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for i, hero := range ds.Heroes {
+		if fetched[i] != nil {
+			counters[hero.ID] = fetched[i]
 			continue
 		}
-		log.Info().Msgf("%d/%d: %s has %d counters", i+1, len(e.Heroes), hero.Name, len(counters))
-		e.Counters[hero.Name] = counters
-		for _, c := range counters {
-			if _, ok := e.CountersMap[c.Hero.Name]; !ok {
-				e.CountersMap[c.Hero.Name] = make(map[string]*Counter, 0)
-			}
-			e.CountersMap[c.Hero.Name][hero.Name] = c
+		failed++
+		if previous, ok := ds.Counters[hero.ID]; ok {
+			counters[hero.ID] = previous
+		}
+	}
+	if len(counters) > 0 || layoutErr != nil {
+		loaded := ds.withCounters(counters, layoutErr)
+		loaded.countersLoaded = len(counters) > 0
+		e.swapDataset(ds, loaded)
+	}
+	if failed > 0 {
+		log.Error().Str("patch", ds.Patch).Str("filter", ds.Filter.String()).Int("failed", failed).Msg("Kept the previous counters of the heroes that failed")
+		if layoutErr != nil {
+			return layoutErr
 		}
+		return fmt.Errorf("Error fetching the counters of %d/%d heroes for %s in patch %s", failed, len(ds.Heroes), ds.Filter, ds.Patch)
 	}
-	log.Info().Msgf("Counters has been loaded in %0.2f seconds", time.Since(tick).Seconds())
+	log.Info().Str("patch", ds.Patch).Str("filter", ds.Filter.String()).Msgf("Counters has been loaded in %0.2f seconds", time.Since(tick).Seconds())
 	return nil
 }
 
-func (e *Engine) PickWinRate(radiant, dire []*Hero) (float64, float64) {
-	var radiantWinRate, direWinRate float64
-	for _, hero := range radiant {
-		counterArr := make([]*Counter, 0)
-		for _, enemy := range dire {
-			countersOfHero := e.CountersMap[hero.Name]
-			counterArr = append(counterArr, countersOfHero[enemy.Name])
-		}
+// fetchCounters returns the counters in the order of heroes, a hero whose
+// counters could not be fetched gets nil. The second value is the first
+// layout change error met on the way.
+func (e *Engine) fetchCounters(patch string, filter CounterFilter, heroes []*Hero) ([][]*Counter, error) {
+	workers := e.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	res := make([][]*Counter, len(heroes))
+	jobs := make(chan int)
+	var done atomic.Int32
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	var layoutErr error
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hero := heroes[i]
+				counters, err := e.heroCounters(patch, filter, hero)
+				n := done.Add(1)
+				if err != nil {
+					log.Printf("Error fetching counters for %s: %v", hero.Name, err)
+					if IsLayoutChanged(err) {
+						errLock.Lock()
+						if layoutErr == nil {
+							layoutErr = err
+						}
+						errLock.Unlock()
+					}
+					continue
+				}
+				log.Info().Msgf("%d/%d: %s has %d counters", n, len(heroes), hero.Name, len(counters))
+				res[i] = counters
+			}
+		}()
+	}
+	for i := range heroes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return res, layoutErr
+}
+
+// sourceURL returns the URL the data source fetches the data from, or an
+// empty string for sources that do not tell.
+func (e *Engine) sourceURL(fn func(SourceURLs) string) string {
+	if u, ok := e.source.(SourceURLs); ok {
+		return fn(u)
+	}
+	return ""
+}
+
+// SetCacheStore replaces the store the patch data is cached in.
+func (e *Engine) SetCacheStore(cache CacheStore) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.store = NewPatchStore(cache)
+}
 
-		radiantWinRate += hero.WinRateVsPick(counterArr, true, 1)
+// heroCounters returns the counters of the hero from the patch store,
+// fetching them from the source when they are missing or older than a day.
+func (e *Engine) heroCounters(patch string, filter CounterFilter, hero *Hero) ([]*Counter, error) {
+	counters, ok, err := e.store.LoadCounters(patch, filter, hero, true)
+	if err == nil && ok {
+		return counters, nil
 	}
-	for _, hero := range dire {
-		counterArr := make([]*Counter, 0)
-		for _, enemy := range radiant {
-			countersOfHeroMap := e.CountersMap[hero.Name]
-			if countersOfHeroMap == nil {
-				panic(fmt.Sprintf("Counters not found for %s", hero.Name))
+	counters, err = e.source.Counters(hero, filter)
+	if err != nil {
+		return nil, err
+	}
+	url := e.sourceURL(func(u SourceURLs) string { return u.CountersURL(hero, filter) })
+	err = e.store.SaveCounters(patch, filter, hero, url, counters)
+	if err != nil {
+		log.Error().Err(err).Str("hero", hero.Name).Msg("Error saving counters")
+	}
+	return counters, nil
+}
+
+// CheckCounters makes sure every counter needed to score the draft is
+// loaded. When the last load ran into a dotabuff layout change, that error
+// is returned instead of a generic one.
+func (d *Dataset) CheckCounters(radiant, dire []*Hero) error {
+	for _, r := range radiant {
+		for _, dh := range dire {
+			if d.CountersMap[r.ID][dh.ID] != nil && d.CountersMap[dh.ID][r.ID] != nil {
+				continue
 			}
-			heroCounterList := countersOfHeroMap[enemy.Name]
-			if heroCounterList == nil {
-				panic(fmt.Sprintf("Counter not found for %s vs %s", hero.Name, enemy.Name))
+			if d.layoutErr != nil {
+				return d.layoutErr
 			}
-			counterArr = append(counterArr, heroCounterList)
+			return fmt.Errorf("Counter not found for %s vs %s in patch %s", r.Name, dh.Name, d.Patch)
 		}
-		direWinRate += hero.WinRateVsPick(counterArr, false, 1)
 	}
-	totalR := radiantWinRate / 5
-	totalD := direWinRate / 5
-	return totalR, totalD
+	return nil
 }
 
-func (e *Engine) PickWinRateFromLines(all []string) (float64, float64, error) {
+// DatasetForPatch returns the dataset of the patch, the current one for an
+// empty patch. Past patches are loaded from the patch store only.
+func (e *Engine) DatasetForPatch(patch string) (*Dataset, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if patch == "" || patch == e.Patch {
+		return e.Dataset, nil
+	}
+	if ds, ok := e.datasets[patch]; ok {
+		return ds, nil
+	}
+	heroes, ok, err := e.store.LoadHeroes(patch)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("No data for patch %s", patch)
+	}
+	ds := NewDataset(patch)
+	for _, h := range heroes {
+		if hero := CanonicalHero(h); hero.ID != 0 {
+			ds.Heroes = append(ds.Heroes, hero)
+		}
+	}
+	wrs, _, err := e.store.LoadSideWR(patch, false)
+	if err != nil {
+		return nil, err
+	}
+	ds.SetSideWR(wrs)
+	counters := make(map[int][]*Counter, len(ds.Heroes))
+	for _, hero := range ds.Heroes {
+		heroCounters, ok, err := e.store.LoadCounters(patch, CounterFilter{}, hero, false)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			counters[hero.ID] = heroCounters
+		}
+	}
+	ds.SetCounters(counters)
+	e.datasets[patch] = ds
+	log.Info().Str("patch", patch).Int("heroes", len(counters)).Msg("Loaded dataset from the patch store")
+	return ds, nil
+}
+
+// Key identifies the counters of the dataset in stored predictions.
+func (d *Dataset) Key() string {
+	if d.Table != "" {
+		return d.Table
+	}
+	return d.Filter.Key()
+}
+
+// PickOptions selects the data a draft is scored with, the zero value is
+// the default counters of the current patch. With Counters set to
+// CountersPro an empty Patch means the matches of every patch. An empty
+// Algorithm means DefaultAlgorithm.
+type PickOptions struct {
+	Patch     string
+	Filter    CounterFilter
+	Counters  string
+	Algorithm string
+}
+
+func (o PickOptions) String() string {
+	s := o.Filter.String()
+	if o.Counters == CountersPro {
+		s = "pro match counters"
+	}
+	if o.Algorithm != "" {
+		s += ", algorithm " + o.Algorithm
+	}
+	return s
+}
+
+// DatasetFor returns the dataset matching the options. The counters of a
+// filter are loaded on first use: from the patch store when it has all of
+// them, otherwise they are fetched in the background for the current patch
+// and an error asking to retry later is returned.
+func (e *Engine) DatasetFor(opts PickOptions) (*Dataset, error) {
+	switch opts.Counters {
+	case "":
+	case CountersPro:
+		if !opts.Filter.IsDefault() {
+			return nil, fmt.Errorf("Pro match counters can not be filtered by %s", opts.Filter)
+		}
+		return e.ProDataset(opts.Patch)
+	default:
+		return nil, fmt.Errorf("Unknown counters %q, use %s", opts.Counters, CountersPro)
+	}
+	base, err := e.DatasetForPatch(opts.Patch)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Filter.IsDefault() {
+		return base, nil
+	}
+	err = opts.Filter.Validate()
+	if err != nil {
+		return nil, err
+	}
+	filter := opts.Filter.resolve(base.Patch)
+	e.lock.Lock()
+	ds, ok := base.filtered[filter.Key()]
+	if !ok {
+		ds = base.withFilter(filter)
+		base.filtered[filter.Key()] = ds
+	}
+	current := base == e.Dataset
+	loaded := ds.countersLoaded
+	e.lock.Unlock()
+	if loaded {
+		return ds, nil
+	}
+	if ok {
+		return nil, fmt.Errorf("Counters for %s are still loading, please try again in a minute", filter)
+	}
+	// stale counters are fine for past patches, they will not be refetched
+	counters := make(map[int][]*Counter, len(ds.Heroes))
+	for _, hero := range ds.Heroes {
+		heroCounters, found, err := e.store.LoadCounters(ds.Patch, filter, hero, current)
+		if err == nil && found {
+			counters[hero.ID] = heroCounters
+		}
+	}
+	if len(counters) == len(ds.Heroes) || (!current && len(counters) > 0) {
+		loaded := ds.withCounters(counters, nil)
+		e.lock.Lock()
+		e.swapDataset(ds, loaded)
+		e.lock.Unlock()
+		return loaded, nil
+	}
+	if !current {
+		e.lock.Lock()
+		delete(base.filtered, filter.Key())
+		e.lock.Unlock()
+		return nil, fmt.Errorf("No counters for %s in patch %s", filter, ds.Patch)
+	}
+	log.Info().Str("filter", filter.String()).Msg("Loading counters for a new filter")
+	go func() {
+		err := e.loadDatasetCounters(ds)
+		if err != nil {
+			log.Error().Err(err).Msg("Error loading counters for a new filter")
+		}
+	}()
+	return nil, fmt.Errorf("Loading counters for %s, please try again in a minute", filter)
+}
+
+// PickWinRateWithOptions scores the draft with the data selected by opts.
+func (e *Engine) PickWinRateWithOptions(opts PickOptions, radiant, dire []*Hero) (float64, float64, error) {
+	if !e.Loaded() {
+		return 0, 0, fmt.Errorf("Data has not been loaded yet. Please try again in like 30 seconds")
+	}
+	p, err := PredictorFor(opts.Algorithm)
+	if err != nil {
+		return 0, 0, err
+	}
+	ds, err := e.DatasetFor(opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = ds.CheckCounters(radiant, dire)
+	if err != nil {
+		return 0, 0, err
+	}
+	rw, dw := p.WinRate(ds, radiant, dire)
+	return rw, dw, nil
+}
+
+// NormalizeWinRates turns two independent radiant and dire scores into
+// win probabilities in percent that sum to 100, 50/50 when both are 0.
+func NormalizeWinRates(radiant, dire float64) (float64, float64) {
+	total := radiant + dire
+	if total <= 0 {
+		return 50, 50
+	}
+	rw := radiant / total * 100
+	return rw, 100 - rw
+}
+
+// PickWinRate returns the radiant and dire win probabilities of the draft
+// in percent, they always sum to 100. See RawPickWinRate for the scores
+// they come from.
+func (d *Dataset) PickWinRate(radiant, dire []*Hero) (float64, float64) {
+	return NormalizeWinRates(d.RawPickWinRate(radiant, dire))
+}
+
+// RawPickWinRate averages the winrates of every hero against the enemy
+// heroes, for each side on its own, so the two scores rarely sum to 100.
+func (d *Dataset) RawPickWinRate(radiant, dire []*Hero) (float64, float64) {
+	return d.pairWinRates(radiant, dire)
+}
+
+// pairWinRates averages the winrate of every radiant hero against every
+// dire hero and the other way around, over the hero pairs of the draft.
+func (d *Dataset) pairWinRates(radiant, dire []*Hero) (float64, float64) {
+	var radiantWinRate, direWinRate float64
+	for _, r := range radiant {
+		for _, dh := range dire {
+			radiantWinRate += d.pairCounter(r, dh).WinRate
+			direWinRate += d.pairCounter(dh, r).WinRate
+		}
+	}
+	pairs := float64(len(radiant) * len(dire))
+	return radiantWinRate / pairs, direWinRate / pairs
+}
+
+func (d *Dataset) pairCounter(hero, enemy *Hero) *Counter {
+	countersOfHeroMap := d.CountersMap[hero.ID]
+	if countersOfHeroMap == nil {
+		panic(fmt.Sprintf("Counters not found for %s", hero.Name))
+	}
+	heroCounter := countersOfHeroMap[enemy.ID]
+	if heroCounter == nil {
+		panic(fmt.Sprintf("Counter not found for %s vs %s", hero.Name, enemy.Name))
+	}
+	return heroCounter
+}
+
+func (e *Engine) PickWinRateFromLines(all []string, opts PickOptions) (float64, float64, error) {
 	if !e.Loaded() {
 		return 0, 0, fmt.Errorf("Data has not been loaded yet. Please try again in like 30 seconds")
 	}
@@ -201,8 +727,7 @@ func (e *Engine) PickWinRateFromLines(all []string) (float64, float64, error) {
 	if err != nil {
 		return 0, 0, err
 	}
-	radiantWinRate, direWinRate := e.PickWinRate(radiantHeroes, direHeroes)
-	return radiantWinRate, direWinRate, nil
+	return e.PickWinRateWithOptions(opts, radiantHeroes, direHeroes)
 }
 
 func (e *Engine) SplitToDireAndRadiant(all []string) ([]*Hero, []*Hero, error) {
@@ -219,20 +744,65 @@ func (e *Engine) SplitToDireAndRadiant(all []string) ([]*Hero, []*Hero, error) {
 	return radiantHeroes, direHeroes, nil
 }
 
-func (e *Engine) PickWinRateFromDBMatch(match *DotabuffMatch) (float64, float64, error) {
-	if !e.Loaded() {
-		return 0, 0, fmt.Errorf("Data has not been loaded yet. Please try again in like 30 seconds")
+// Match fetches the draft of the match from the engine's data source.
+func (e *Engine) Match(id int64) (*DotabuffMatch, error) {
+	return e.source.Match(id)
+}
+
+// LeagueMatches lists the matches of the league from the engine's data
+// source.
+func (e *Engine) LeagueMatches(leagueID int64) ([]int64, error) {
+	return e.source.LeagueMatches(leagueID)
+}
+
+func (e *Engine) PickWinRateFromDBMatch(match *DotabuffMatch, opts PickOptions) (float64, float64, error) {
+	p, err := e.PredictMatch(match, opts)
+	if err != nil {
+		return 0, 0, err
 	}
-	rw, dw := e.PickWinRate(match.Radiant, match.Dire)
 	if e.mysql != nil {
-		go e.mysql.InsertDotabuffMatch(match, "v1.1", rw, dw)
+		e.inserts.Add(1)
+		go func() {
+			defer e.inserts.Done()
+			e.mysql.InsertDotabuffMatchPredictions(match, e.storedPredictions(match, opts, p))
+		}()
 	}
-	return rw, dw, nil
+	return p.RadiantWin, p.DireWin, nil
+}
+
+// WaitInserts blocks until the matches scored by PickWinRateFromDBMatch
+// are stored, command line runs call it before exiting.
+func (e *Engine) WaitInserts() {
+	e.inserts.Wait()
+}
+
+// PredictMatch scores the draft of the match without storing it.
+func (e *Engine) PredictMatch(match *DotabuffMatch, opts PickOptions) (*Prediction, error) {
+	if !e.Loaded() {
+		return nil, fmt.Errorf("Data has not been loaded yet. Please try again in like 30 seconds")
+	}
+	p, err := PredictorFor(opts.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return e.predict(p, match, opts)
+}
+
+// StoreMatch scores the match and waits until it is stored in MySQL.
+func (e *Engine) StoreMatch(match *DotabuffMatch, opts PickOptions) (*Prediction, error) {
+	if e.mysql == nil {
+		return nil, fmt.Errorf("MySQL is not configured")
+	}
+	p, err := e.PredictMatch(match, opts)
+	if err != nil {
+		return nil, err
+	}
+	return p, e.mysql.InsertDotabuffMatchPredictions(match, e.storedPredictions(match, opts, p))
 }
 
 // GenerateHeatMap generates a heatmap of the winrate of the heroes
 // you selected and returns the path to the image.
-func (e *Engine) GenerateHeatMap(all []string) (string, error) {
+func (e *Engine) GenerateHeatMap(all []string, opts PickOptions) (string, error) {
 	radiantHeroes, direHeroes, err := e.SplitToDireAndRadiant(all)
 	if err != nil {
 		return "", err
@@ -244,6 +814,36 @@ func (e *Engine) GenerateHeatMap(all []string) (string, error) {
 	for _, name := range direHeroes {
 		heroesFullNames = append(heroesFullNames, name.Name)
 	}
+	return e.generateHeatMap(radiantHeroes, direHeroes, heroesFullNames, opts)
+}
+
+// GenerateMatchHeatMap generates the heatmap of a match with the heroes
+// of both sides ordered from position 1 to 5.
+func (e *Engine) GenerateMatchHeatMap(match *DotabuffMatch, opts PickOptions) (string, error) {
+	radiantHeroes := match.HeroesByPosition(true)
+	direHeroes := match.HeroesByPosition(false)
+	labels := make([]string, 0, 10)
+	for _, heroes := range [][]*Hero{radiantHeroes, direHeroes} {
+		for i, hero := range heroes {
+			if len(match.Players) == 10 {
+				labels = append(labels, fmt.Sprintf("%d. %s", i+1, hero.Name))
+			} else {
+				labels = append(labels, hero.Name)
+			}
+		}
+	}
+	return e.generateHeatMap(radiantHeroes, direHeroes, labels, opts)
+}
+
+func (e *Engine) generateHeatMap(radiantHeroes, direHeroes []*Hero, heroesFullNames []string, opts PickOptions) (string, error) {
+	ds, err := e.DatasetFor(opts)
+	if err != nil {
+		return "", err
+	}
+	err = ds.CheckCounters(radiantHeroes, direHeroes)
+	if err != nil {
+		return "", err
+	}
 	resCmdArg := ""
 	heroesStr := strings.Join(heroesFullNames, ",")
 	resCmdArg += fmt.Sprintf("--heroes=\"%s\"", heroesStr)
@@ -251,17 +851,17 @@ func (e *Engine) GenerateHeatMap(all []string) (string, error) {
 	for _, rHero := range radiantHeroes {
 		for _, dHero := range direHeroes {
 			log.Info().Msgf("rHero: %s, dHero: %s", rHero.Name, dHero.Name)
-			log.Info().Msgf("Couneter: %v", e.CountersMap[dHero.Name][rHero.Name])
-			counterMap := e.CountersMap[dHero.Name]
+			log.Info().Msgf("Couneter: %v", ds.CountersMap[dHero.ID][rHero.ID])
+			counterMap := ds.CountersMap[dHero.ID]
 			if counterMap == nil {
 				log.Info().Msg("counterMap is null")
 			}
-			counterF := counterMap[rHero.Name]
+			counterF := counterMap[rHero.ID]
 			if counterF == nil {
 				log.Info().Msg("CounterF is null")
 			}
 			log.Info().Msgf("Winrate: %v", counterF.WinRate)
-			winRatesArg += fmt.Sprintf("%.2f,", e.CountersMap[dHero.Name][rHero.Name].WinRate)
+			winRatesArg += fmt.Sprintf("%.2f,", ds.CountersMap[dHero.ID][rHero.ID].WinRate)
 		}
 		winRatesArg = strings.TrimSuffix(winRatesArg, ",")
 		if rHero != radiantHeroes[len(radiantHeroes)-1] {
@@ -269,10 +869,17 @@ func (e *Engine) GenerateHeatMap(all []string) (string, error) {
 		}
 	}
 	resCmdArg += fmt.Sprintf(" --winrates=\"%s\"", winRatesArg)
+	// the title shows the same normalized win chance as the text replies
+	predictor, err := PredictorFor(opts.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	p := Score(predictor, ds, radiantHeroes, direHeroes)
 	out := uuid.New().String() + ".png"
 	cmd := exec.Command("python3", "heatmap.py",
 		fmt.Sprintf("--heroes=%s", heroesStr),
 		fmt.Sprintf("--winrates=%s", winRatesArg),
+		fmt.Sprintf("--radiant-win=%.2f", p.RadiantWin),
 		fmt.Sprintf("--out=%s", out))
 	execAndPrint(cmd)
 	return out, nil
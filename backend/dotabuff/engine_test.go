@@ -0,0 +1,230 @@
+package dotabuff
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fixtureCounters gives the i-th hero a winrate of 50+i-j against the
+// j-th one, so the first heroes are the weakest.
+func fixtureCounters(heroes []*Hero) map[int][]*Counter {
+	counters := make(map[int][]*Counter, len(heroes))
+	for j, x := range heroes {
+		for i, y := range heroes {
+			if i == j {
+				continue
+			}
+			counters[x.ID] = append(counters[x.ID], &Counter{
+				Hero:          y,
+				WinRate:       50 + float64(i-j),
+				Disadvantage:  float64(i - j),
+				MatchesPlayed: 100,
+			})
+		}
+	}
+	return counters
+}
+
+func newFixtureEngine(t *testing.T, source DataSource) *Engine {
+	t.Helper()
+	e := NewEngine(nil, source)
+	e.SetCacheStore(NewMemoryCache())
+	return e
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestEngineLoadsFixture(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, fixtureCounters(heroes)))
+	err := e.LoadHeroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Patch != "fixture" || len(e.Heroes) != len(heroes) {
+		t.Fatalf("got patch %q with %d heroes", e.Patch, len(e.Heroes))
+	}
+	if e.Loaded() {
+		t.Fatal("engine is loaded before its counters")
+	}
+	err = e.LoadCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Loaded() {
+		t.Fatal("engine is not loaded after LoadCounters")
+	}
+	if hero, ok := e.FindHero(strings.ToLower(heroes[0].Name)); !ok || hero.ID != heroes[0].ID {
+		t.Fatalf("hero %s not found by name", heroes[0].Name)
+	}
+
+	// radiant is heroes 0-4 and dire 5-9: radiant averages 50+(2-7)
+	rawRadiant, rawDire := e.RawPickWinRate(heroes[:5], heroes[5:])
+	if !approxEqual(rawRadiant, 45) || !approxEqual(rawDire, 55) {
+		t.Fatalf("raw winrates are %v/%v, want 45/55", rawRadiant, rawDire)
+	}
+	rw, dw, err := e.PickWinRateWithOptions(PickOptions{}, heroes[:5], heroes[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approxEqual(rw, 45) || !approxEqual(dw, 55) {
+		t.Fatalf("winrates are %v/%v, want 45/55", rw, dw)
+	}
+}
+
+func TestEngineMissingCounters(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	counters := fixtureCounters(heroes)
+	missing := heroes[7]
+	delete(counters, missing.ID)
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, counters))
+	err := e.LoadHeroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.LoadCounters()
+	if err == nil {
+		t.Fatal("LoadCounters did not report the missing counters")
+	}
+	if !e.Loaded() {
+		t.Fatal("engine is not loaded with the counters of the other heroes")
+	}
+	_, _, err = e.PickWinRateWithOptions(PickOptions{}, heroes[:5], heroes[5:])
+	if err == nil || !strings.Contains(err.Error(), "Counter not found") {
+		t.Fatalf("got %v, want a missing counter error", err)
+	}
+	// drafts without the hero are still scored
+	_, _, err = e.PickWinRateWithOptions(PickOptions{}, heroes[:4], []*Hero{heroes[5], heroes[6], heroes[8], heroes[9]})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEngineKeepsCountersOnFailedRefresh(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	src := NewFixtureSource(heroes, nil, fixtureCounters(heroes))
+	e := newFixtureEngine(t, src)
+	err := e.LoadHeroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.LoadCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an outage of the source with nothing cached
+	src.counters = make(map[int][]*Counter)
+	e.SetCacheStore(NewMemoryCache())
+	err = e.LoadCounters()
+	if err == nil {
+		t.Fatal("LoadCounters did not fail")
+	}
+	rw, _, err := e.PickWinRateWithOptions(PickOptions{}, heroes[:5], heroes[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approxEqual(rw, 45) {
+		t.Fatalf("got %v, want the previous 45", rw)
+	}
+}
+
+func TestEngineNotLoadedWithoutCounters(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, nil))
+	err := e.LoadHeroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.LoadCounters()
+	if err == nil {
+		t.Fatal("LoadCounters did not fail")
+	}
+	if e.Loaded() {
+		t.Fatal("engine is loaded without counters")
+	}
+}
+
+// TestEngineReloadWhileScoring is meant for go test -race.
+func TestEngineReloadWhileScoring(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, fixtureCounters(heroes)))
+	err := e.LoadHeroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.LoadCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			e.LoadCounters()
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		_, _, err := e.PickWinRateWithOptions(PickOptions{Algorithm: AlgorithmLogOdds}, heroes[:5], heroes[5:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
+
+// TestEngineLoadedWhileLocked checks that a prediction holding the lock
+// does not make a loaded engine look unloaded to the others.
+func TestEngineLoadedWhileLocked(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, fixtureCounters(heroes)))
+	err := e.LoadHeroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.LoadCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.lock.Lock()
+	loaded := e.Loaded()
+	e.lock.Unlock()
+	if !loaded {
+		t.Fatal("engine is not loaded while its lock is held")
+	}
+}
+
+func TestDatasetKeepsInputsUnmodified(t *testing.T) {
+	am, _ := HeroByID(1)
+	axe, _ := HeroByID(2)
+	stale := &Hero{ID: 2, Name: axe.Name}
+	counter := &Counter{Hero: stale, WinRate: 52}
+	wr := &RadiantDireWinrate{Hero: &Hero{ID: 1}, RadiantWinrate: 51, DireWinrate: 49}
+	ds := NewDataset("fixture")
+	ds.SetCounters(map[int][]*Counter{am.ID: {counter}})
+	ds.SetSideWR([]*RadiantDireWinrate{wr})
+	if counter.Hero != stale || wr.Hero.Name != "" {
+		t.Fatal("the inputs have been modified")
+	}
+	if ds.CountersMap[axe.ID][am.ID].Hero != axe || ds.Counters[am.ID][0].Hero != axe {
+		t.Fatal("the counters of the dataset are not canonical")
+	}
+	if ds.HeroSideWR[am.ID].Hero != am || ds.SideWR[0].Hero != am {
+		t.Fatal("the side winrates of the dataset are not canonical")
+	}
+}
+
+func TestNormalizeWinRates(t *testing.T) {
+	rw, dw := NormalizeWinRates(51.3, 50.1)
+	if !approxEqual(rw+dw, 100) || rw <= 50 {
+		t.Fatalf("got %v/%v", rw, dw)
+	}
+	rw, dw = NormalizeWinRates(0, 0)
+	if rw != 50 || dw != 50 {
+		t.Fatalf("got %v/%v, want 50/50", rw, dw)
+	}
+}
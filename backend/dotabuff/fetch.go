@@ -0,0 +1,281 @@
+package dotabuff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+type FetchErrorKind int
+
+const (
+	FetchNetwork FetchErrorKind = iota
+	FetchRateLimited
+	FetchNotFound
+	FetchServer
+	FetchHTTP
+	FetchParse
+	FetchCassetteMiss
+)
+
+func (k FetchErrorKind) String() string {
+	switch k {
+	case FetchNetwork:
+		return "network"
+	case FetchRateLimited:
+		return "rate limited"
+	case FetchNotFound:
+		return "not found"
+	case FetchServer:
+		return "server error"
+	case FetchHTTP:
+		return "http error"
+	case FetchParse:
+		return "parse error"
+	case FetchCassetteMiss:
+		return "not recorded in cassette"
+	}
+	return "unknown"
+}
+
+// FetchError is returned by the Fetcher once it gives up on a URL.
+type FetchError struct {
+	Kind       FetchErrorKind
+	URL        string
+	StatusCode int
+	Attempts   int
+	Err        error
+}
+
+func (e *FetchError) Error() string {
+	msg := fmt.Sprintf("Error fetching %s: %v", e.URL, e.Kind)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" after %d attempts", e.Attempts)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+func fetchErrorIs(err error, kind FetchErrorKind) bool {
+	var fe *FetchError
+	return errors.As(err, &fe) && fe.Kind == kind
+}
+
+func IsRateLimited(err error) bool {
+	return fetchErrorIs(err, FetchRateLimited)
+}
+
+func IsNotFound(err error) bool {
+	return fetchErrorIs(err, FetchNotFound)
+}
+
+func IsFetchParseError(err error) bool {
+	return fetchErrorIs(err, FetchParse)
+}
+
+// Fetcher downloads pages with bounded retries. Network errors, 5xx and
+// 429 responses are retried with exponential backoff and jitter, a
+// Retry-After header takes precedence over the computed backoff. A 429
+// asking to wait longer than MaxBackoff fails right away.
+// Every attempt waits for the Limiter first when one is set. With a
+// Cassette the responses are recorded or replayed.
+type Fetcher struct {
+	Client      *http.Client
+	Limiter     *HostRateLimiter
+	Cassette    *Cassette
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+var DefaultFetcher = NewFetcher(30*time.Second, 5)
+
+func NewFetcher(timeout time.Duration, maxRetries int) *Fetcher {
+	return &Fetcher{
+		Client:      &http.Client{Timeout: timeout},
+		MaxRetries:  maxRetries,
+		BaseBackoff: 2 * time.Second,
+		MaxBackoff:  2 * time.Minute,
+	}
+}
+
+// Get returns the body of a 200 response.
+func (f *Fetcher) Get(url string) ([]byte, error) {
+	var last *FetchError
+	for attempt := 0; attempt <= f.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := f.backoff(attempt)
+			if ra, ok := last.retryAfter(); ok {
+				wait = ra
+			}
+			log.Warn().Str("url", url).Int("attempt", attempt).Dur("wait", wait).Msgf("Retrying after %v", last.Kind)
+			time.Sleep(wait)
+		}
+		body, fe := f.get(url)
+		if fe == nil {
+			return body, nil
+		}
+		fe.Attempts = attempt + 1
+		last = fe
+		if !fe.retriable() {
+			break
+		}
+		if wait, ok := fe.retryAfter(); ok && wait > f.MaxBackoff {
+			log.Warn().Str("url", url).Dur("retry_after", wait).Msg("Rate limited for longer than the max backoff, giving up")
+			break
+		}
+	}
+	log.Error().Err(last).Msg("Error fetching page")
+	return nil, last
+}
+
+func (f *Fetcher) get(url string) ([]byte, *FetchError) {
+	entry, fe := f.roundTrip(url)
+	if fe != nil {
+		return nil, fe
+	}
+	switch {
+	case entry.Status == http.StatusTooManyRequests:
+		fe := &FetchError{Kind: FetchRateLimited, URL: url, StatusCode: entry.Status}
+		if wait, ok := parseRetryAfter(entry.Header.Get("Retry-After")); ok {
+			fe.Err = retryAfterError(wait)
+		}
+		return nil, fe
+	case entry.Status == http.StatusNotFound:
+		return nil, &FetchError{Kind: FetchNotFound, URL: url, StatusCode: entry.Status}
+	case entry.Status >= 500:
+		return nil, &FetchError{Kind: FetchServer, URL: url, StatusCode: entry.Status}
+	case entry.Status != http.StatusOK:
+		return nil, &FetchError{Kind: FetchHTTP, URL: url, StatusCode: entry.Status}
+	}
+	return []byte(entry.Body), nil
+}
+
+// roundTrip returns the response for the url either from the network or,
+// in replay mode, from the cassette. Transient responses (429 and 5xx) are
+// not recorded.
+func (f *Fetcher) roundTrip(url string) (*CassetteEntry, *FetchError) {
+	if f.Cassette != nil && f.Cassette.Mode == CassetteReplay {
+		entry, err := f.Cassette.Load(url)
+		if err != nil {
+			return nil, &FetchError{Kind: FetchCassetteMiss, URL: url, Err: err}
+		}
+		return entry, nil
+	}
+	f.Limiter.Wait(url)
+	resp, err := f.Client.Get(url)
+	if err != nil {
+		return nil, &FetchError{Kind: FetchNetwork, URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &FetchError{Kind: FetchNetwork, URL: url, Err: err}
+	}
+	entry := &CassetteEntry{
+		URL:    url,
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   string(body),
+	}
+	transient := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	if f.Cassette != nil && f.Cassette.Mode == CassetteRecord && !transient {
+		err = f.Cassette.Save(entry)
+		if err != nil {
+			log.Error().Err(err).Str("url", url).Msg("Error recording response")
+		}
+	}
+	return entry, nil
+}
+
+func (e *FetchError) retriable() bool {
+	return e.Kind == FetchNetwork || e.Kind == FetchRateLimited || e.Kind == FetchServer
+}
+
+// backoff returns a random duration in [d/2, d] where d doubles with
+// every attempt up to MaxBackoff.
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	d := f.BaseBackoff << (attempt - 1)
+	if d <= 0 || d > f.MaxBackoff {
+		d = f.MaxBackoff
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+type retryAfterError time.Duration
+
+// retryAfter returns the wait asked by the Retry-After header of a 429.
+func (e *FetchError) retryAfter() (time.Duration, bool) {
+	var ra retryAfterError
+	if e.Kind != FetchRateLimited || !errors.As(e.Err, &ra) {
+		return 0, false
+	}
+	return time.Duration(ra), true
+}
+
+func (r retryAfterError) Error() string {
+	return fmt.Sprintf("retry after %v", time.Duration(r))
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+func (f *Fetcher) GetAndParse(url string) (*html.Node, error) {
+	body, err := f.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := htmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		log.Error().Err(err).Msg("Error parsing body")
+		return nil, &FetchError{Kind: FetchParse, URL: url, Err: err}
+	}
+	return parsed, nil
+}
+
+func (f *Fetcher) GetJSON(url string, v any) error {
+	body, err := f.Get(url)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Error decoding json")
+		return &FetchError{Kind: FetchParse, URL: url, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package dotabuff
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRateLimitedServer(t *testing.T, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests
+}
+
+func TestFetcherRetryAfterOverMaxBackoff(t *testing.T) {
+	s, requests := newRateLimitedServer(t, "3600")
+	f := NewFetcher(5*time.Second, 3)
+	f.BaseBackoff = time.Millisecond
+	f.MaxBackoff = 10 * time.Millisecond
+	tick := time.Now()
+	_, err := f.Get(s.URL)
+	if !IsRateLimited(err) {
+		t.Fatalf("got %v, want rate limited", err)
+	}
+	if requests.Load() != 1 || time.Since(tick) > time.Second {
+		t.Fatalf("retried %d times in %v", requests.Load()-1, time.Since(tick))
+	}
+}
+
+func TestFetcherRetryAfter(t *testing.T) {
+	s, requests := newRateLimitedServer(t, "0")
+	f := NewFetcher(5*time.Second, 3)
+	f.BaseBackoff = time.Millisecond
+	f.MaxBackoff = 10 * time.Millisecond
+	body, err := f.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" || requests.Load() != 2 {
+		t.Fatalf("got %q after %d requests", body, requests.Load())
+	}
+}
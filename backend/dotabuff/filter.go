@@ -0,0 +1,121 @@
+package dotabuff
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// CounterFilter selects the view of the dotabuff counters page: the time
+// window of the matches and the skill bracket of the players. The zero
+// value is the default view of the page.
+type CounterFilter struct {
+	Window  string `json:"window"`
+	Bracket string `json:"bracket"`
+}
+
+// Windows accepted by CounterFilter. WindowPatch is the current patch of
+// the dataset and is resolved to "patch_<name>" before fetching.
+const (
+	WindowWeek  = "week"
+	WindowMonth = "month"
+	WindowPatch = "patch"
+)
+
+// counterBrackets maps the bracket names we accept to the values of the
+// dotabuff skill bracket query parameter.
+var counterBrackets = map[string]string{
+	"herald_guardian": "herald_guardian",
+	"crusader_archon": "crusader_archon",
+	"legend_ancient":  "legend_ancient",
+	"divine_immortal": "divine_immortal",
+}
+
+const dotabuffBracketParam = "skill_bracket"
+
+// ParseCounterFilter parses "<window> <bracket>" where both parts are
+// optional and may come in any order, e.g. "month divine_immortal".
+func ParseCounterFilter(s string) (CounterFilter, error) {
+	var f CounterFilter
+	for _, part := range strings.Fields(strings.ToLower(s)) {
+		if _, ok := counterBrackets[part]; ok {
+			f.Bracket = part
+		} else {
+			f.Window = part
+		}
+	}
+	return f, f.Validate()
+}
+
+func (f CounterFilter) Validate() error {
+	switch {
+	case f.Window == "", f.Window == WindowWeek, f.Window == WindowMonth, f.Window == WindowPatch:
+	case strings.HasPrefix(f.Window, "patch_") && validPatch(strings.TrimPrefix(f.Window, "patch_")) == nil:
+	default:
+		return fmt.Errorf("Unknown window %q, use %s, %s or %s", f.Window, WindowWeek, WindowMonth, WindowPatch)
+	}
+	if _, ok := counterBrackets[f.Bracket]; f.Bracket != "" && !ok {
+		return fmt.Errorf("Unknown bracket %q, use one of %s", f.Bracket, strings.Join(CounterBrackets(), ", "))
+	}
+	return nil
+}
+
+func CounterBrackets() []string {
+	res := make([]string, 0, len(counterBrackets))
+	for b := range counterBrackets {
+		res = append(res, b)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (f CounterFilter) IsDefault() bool {
+	return f.Window == "" && f.Bracket == ""
+}
+
+// resolve replaces the patch window with the actual patch.
+func (f CounterFilter) resolve(patch string) CounterFilter {
+	if f.Window == WindowPatch {
+		f.Window = "patch_" + patch
+	}
+	return f
+}
+
+// Key identifies the filter in caches, it is empty for the default view.
+func (f CounterFilter) Key() string {
+	if f.IsDefault() {
+		return ""
+	}
+	window, bracket := f.Window, f.Bracket
+	if window == "" {
+		window = "all"
+	}
+	if bracket == "" {
+		bracket = "all"
+	}
+	return window + "-" + bracket
+}
+
+func (f CounterFilter) String() string {
+	if f.IsDefault() {
+		return "default counters"
+	}
+	return strings.ReplaceAll(f.Key(), "-", " ")
+}
+
+// Query returns the dotabuff query string of the filter including the
+// leading "?", or an empty string for the default view.
+func (f CounterFilter) Query() string {
+	v := url.Values{}
+	if f.Window != "" {
+		v.Set("date", f.Window)
+	}
+	if f.Bracket != "" {
+		v.Set(dotabuffBracketParam, counterBrackets[f.Bracket])
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
@@ -0,0 +1,242 @@
+package dotabuff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LiveMatch is a pro match that is drafting or being played. Heroes that
+// are not picked yet are missing from Radiant and Dire.
+type LiveMatch struct {
+	MatchID        int64
+	LeagueID       int64
+	RadiantTeam    *Team
+	DireTeam       *Team
+	Radiant        []*Hero
+	Dire           []*Hero
+	TournamentLink string
+}
+
+// DraftComplete reports whether all ten heroes are picked.
+func (m *LiveMatch) DraftComplete() bool {
+	return len(m.Radiant) == 5 && len(m.Dire) == 5
+}
+
+func (m *LiveMatch) toMatch() *DotabuffMatch {
+	return &DotabuffMatch{
+		MatchID:        m.MatchID,
+		Radiant:        m.Radiant,
+		Dire:           m.Dire,
+		RadiantTeam:    m.RadiantTeam,
+		DireTeam:       m.DireTeam,
+		TournamentLink: m.TournamentLink,
+	}
+}
+
+// LiveFeed lists the pro matches that are live right now.
+type LiveFeed interface {
+	Live() ([]*LiveMatch, error)
+}
+
+// OpenDotaLiveFeed reads the live games of OpenDota and keeps the league
+// ones.
+type OpenDotaLiveFeed struct {
+	Source *OpenDotaSource
+}
+
+func NewOpenDotaLiveFeed(baseURL string) *OpenDotaLiveFeed {
+	return &OpenDotaLiveFeed{Source: NewOpenDotaSource(baseURL)}
+}
+
+// openDotaID is an ID OpenDota sends either as a number or as a string.
+type openDotaID int64
+
+func (id *openDotaID) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*id = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid id %s: %v", b, err)
+	}
+	*id = openDotaID(v)
+	return nil
+}
+
+type openDotaLivePlayer struct {
+	HeroID int `json:"hero_id"`
+	// Team is 0 for radiant and 1 for dire
+	Team int `json:"team"`
+}
+
+type openDotaLiveMatch struct {
+	MatchID         openDotaID           `json:"match_id"`
+	LeagueID        int64                `json:"league_id"`
+	TeamNameRadiant string               `json:"team_name_radiant"`
+	TeamNameDire    string               `json:"team_name_dire"`
+	TeamIDRadiant   int64                `json:"team_id_radiant"`
+	TeamIDDire      int64                `json:"team_id_dire"`
+	Players         []openDotaLivePlayer `json:"players"`
+}
+
+func (f *OpenDotaLiveFeed) Live() ([]*LiveMatch, error) {
+	var raw []json.RawMessage
+	err := f.Source.getJSON("/live", &raw)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*LiveMatch, 0)
+	for _, r := range raw {
+		var m openDotaLiveMatch
+		err := json.Unmarshal(r, &m)
+		if err != nil {
+			log.Warn().Err(err).Msg("Skipping live match")
+			continue
+		}
+		if m.LeagueID == 0 || m.MatchID == 0 {
+			continue
+		}
+		live := &LiveMatch{
+			MatchID:        int64(m.MatchID),
+			LeagueID:       m.LeagueID,
+			RadiantTeam:    openDotaTeamToTeam(&openDotaTeam{TeamID: m.TeamIDRadiant, Name: m.TeamNameRadiant}, "", "Radiant"),
+			DireTeam:       openDotaTeamToTeam(&openDotaTeam{TeamID: m.TeamIDDire, Name: m.TeamNameDire}, "", "Dire"),
+			Radiant:        make([]*Hero, 0, 5),
+			Dire:           make([]*Hero, 0, 5),
+			TournamentLink: fmt.Sprintf("https://www.dotabuff.com/esports/leagues/%d", m.LeagueID),
+		}
+		for _, p := range m.Players {
+			if p.HeroID == 0 {
+				continue
+			}
+			hero, err := f.Source.hero(p.HeroID)
+			if err != nil {
+				log.Warn().Err(err).Int64("match", live.MatchID).Msg("Skipping live hero")
+				continue
+			}
+			if p.Team == 0 {
+				live.Radiant = append(live.Radiant, hero)
+			} else {
+				live.Dire = append(live.Dire, hero)
+			}
+		}
+		res = append(res, live)
+	}
+	return res, nil
+}
+
+// LivePrediction is the prediction of a live match made right after its
+// draft was complete.
+type LivePrediction struct {
+	Match       *LiveMatch
+	Prediction  *Prediction
+	PredictedAt time.Time
+}
+
+// LivePoller watches the live feed and predicts every pro match once its
+// draft is complete. Predictions are stored in MySQL and pushed to the
+// subscribers.
+type LivePoller struct {
+	Engine   *Engine
+	Feed     LiveFeed
+	Interval time.Duration
+	Options  PickOptions
+
+	lock        sync.Mutex
+	seen        map[int64]bool
+	predicted   map[int64]bool
+	subscribers []func(*LivePrediction)
+}
+
+func NewLivePoller(engine *Engine, feed LiveFeed, interval time.Duration) *LivePoller {
+	return &LivePoller{
+		Engine:    engine,
+		Feed:      feed,
+		Interval:  interval,
+		seen:      make(map[int64]bool),
+		predicted: make(map[int64]bool),
+	}
+}
+
+// Subscribe registers a function called with every new prediction.
+func (p *LivePoller) Subscribe(fn func(*LivePrediction)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.subscribers = append(p.subscribers, fn)
+}
+
+func (p *LivePoller) Start() {
+	log.Info().Dur("interval", p.Interval).Msg("Starting live match poller")
+	for {
+		err := p.Poll()
+		if err != nil {
+			log.Error().Err(err).Msg("Error polling live matches")
+		}
+		time.Sleep(p.Interval)
+	}
+}
+
+// Poll checks the feed once and returns after the predictions of the
+// matches whose draft got complete have been pushed.
+func (p *LivePoller) Poll() error {
+	matches, err := p.Feed.Live()
+	if err != nil {
+		return err
+	}
+	live := make(map[int64]bool, len(matches))
+	for _, m := range matches {
+		live[m.MatchID] = true
+		p.lock.Lock()
+		isNew := !p.seen[m.MatchID]
+		p.seen[m.MatchID] = true
+		done := p.predicted[m.MatchID]
+		p.lock.Unlock()
+		if isNew {
+			log.Info().Int64("match", m.MatchID).Str("radiant", m.RadiantTeam.Name).Str("dire", m.DireTeam.Name).Msg("New live match")
+		}
+		if done || !m.DraftComplete() || !p.Engine.Loaded() {
+			continue
+		}
+		p.predict(m)
+	}
+	// forget the matches that are over
+	p.lock.Lock()
+	for id := range p.seen {
+		if !live[id] {
+			delete(p.seen, id)
+			delete(p.predicted, id)
+		}
+	}
+	p.lock.Unlock()
+	return nil
+}
+
+func (p *LivePoller) predict(m *LiveMatch) {
+	prediction, err := p.Engine.PredictMatch(m.toMatch(), p.Options)
+	if err != nil {
+		log.Error().Err(err).Int64("match", m.MatchID).Msg("Error predicting live match")
+		return
+	}
+	lp := &LivePrediction{Match: m, Prediction: prediction, PredictedAt: time.Now()}
+	p.lock.Lock()
+	p.predicted[m.MatchID] = true
+	subscribers := append([]func(*LivePrediction){}, p.subscribers...)
+	p.lock.Unlock()
+	log.Info().Int64("match", m.MatchID).Float64("radiant", prediction.RadiantWin).Float64("dire", prediction.DireWin).Msg("Live match has been predicted")
+	if p.Engine.mysql != nil {
+		for _, stored := range p.Engine.storedPredictions(m.toMatch(), p.Options, prediction) {
+			p.Engine.mysql.InsertLivePrediction(&LivePrediction{Match: m, Prediction: stored, PredictedAt: lp.PredictedAt})
+		}
+	}
+	for _, fn := range subscribers {
+		fn(lp)
+	}
+}
@@ -0,0 +1,74 @@
+package dotabuff
+
+import "testing"
+
+// fixtureFeed serves the live matches it is given.
+type fixtureFeed struct {
+	matches []*LiveMatch
+}
+
+func (f *fixtureFeed) Live() ([]*LiveMatch, error) {
+	return f.matches, nil
+}
+
+func fixtureLiveMatch(id int64, heroes []*Hero) *LiveMatch {
+	return &LiveMatch{
+		MatchID:     id,
+		LeagueID:    16935,
+		RadiantTeam: &Team{Name: "Radiant"},
+		DireTeam:    &Team{Name: "Dire"},
+		Radiant:     heroes[:5],
+		Dire:        heroes[5:],
+	}
+}
+
+func TestLivePoller(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, fixtureCounters(heroes)))
+	feed := &fixtureFeed{}
+	p := NewLivePoller(e, feed, 0)
+	predicted := make([]*LivePrediction, 0)
+	p.Subscribe(func(lp *LivePrediction) { predicted = append(predicted, lp) })
+	poll := func(want int) {
+		t.Helper()
+		err := p.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(predicted) != want {
+			t.Fatalf("got %d predictions, want %d", len(predicted), want)
+		}
+	}
+
+	drafting := fixtureLiveMatch(2, heroes)
+	drafting.Dire = heroes[5:8]
+	feed.matches = []*LiveMatch{fixtureLiveMatch(1, heroes), drafting}
+	// the engine is not loaded yet, the match is predicted once it is
+	poll(0)
+	err := e.LoadHeroes()
+	if err == nil {
+		err = e.LoadCounters()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll(1)
+	if lp := predicted[0]; lp.Match.MatchID != 1 || !approxEqual(lp.Prediction.RadiantWin, 45) {
+		t.Fatalf("got match %d at %v", lp.Match.MatchID, lp.Prediction.RadiantWin)
+	}
+	// already predicted
+	poll(1)
+	drafting.Dire = heroes[5:]
+	poll(2)
+	if predicted[1].Match.MatchID != 2 {
+		t.Fatalf("got match %d, want 2", predicted[1].Match.MatchID)
+	}
+	poll(2)
+
+	// the matches that are over are forgotten
+	feed.matches = nil
+	poll(2)
+	if len(p.seen) != 0 || len(p.predicted) != 0 {
+		t.Fatalf("still tracking %d matches", len(p.seen))
+	}
+}
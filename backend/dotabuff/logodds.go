@@ -0,0 +1,131 @@
+package dotabuff
+
+import (
+	"math"
+)
+
+// LogOddsModel scores a draft as a sum of log-odds contributions instead of
+// averaging raw matchup winrates:
+//
+//	logit(P(radiant)) = side
+//		+ HeroWeight * (sum of logit(base winrate) of radiant - same for dire)
+//		+ MatchupWeight * sum over radiant r and dire d of logit(50% + advantage(r, d))
+//
+// The advantage of r over d is the mean of the Disadvantage of r against d
+// and minus the one of d against r, so it is measured against the baseline
+// of each hero and strong heroes no longer counter everyone. The weights
+// shrink the contributions since the heroes of a draft are not
+// independent.
+type LogOddsModel struct {
+	HeroWeight    float64
+	MatchupWeight float64
+}
+
+// DefaultLogOddsModel counts every hero at half its own log-odds and
+// averages the matchups of a hero over the five opponents, as
+// AveragePredictor does. The weights are picked by hand and not fitted on
+// match results, so its probabilities are uncalibrated: check them with
+// -backtest and -calibration before trusting their scale, and register a
+// new version when fitted weights replace them.
+var DefaultLogOddsModel = LogOddsModel{HeroWeight: 0.5, MatchupWeight: 0.2}
+
+func (m LogOddsModel) Version() string {
+	return AlgorithmLogOdds
+}
+
+// maxAdvantage clamps matchup advantages and base winrates, in percent
+// points from 50, to keep the logits finite on tiny samples.
+const maxAdvantage = 45.0
+
+func logit(winRate float64) float64 {
+	winRate = math.Max(50-maxAdvantage, math.Min(50+maxAdvantage, winRate))
+	p := winRate / 100
+	return math.Log(p / (1 - p))
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// baseWinRate is the winrate of the hero on all sides, 50 when the side
+// winrates are unknown.
+func (d *Dataset) baseWinRate(hero *Hero) float64 {
+	wr, ok := d.HeroSideWR[hero.ID]
+	if !ok || wr.RadiantWinrate == 0 || wr.DireWinrate == 0 {
+		return 50
+	}
+	return (wr.RadiantWinrate + wr.DireWinrate) / 2
+}
+
+// HasSideSplit tells whether the side winrates of the dataset are split by
+// side, without a split the models get no radiant edge.
+func (d *Dataset) HasSideSplit() bool {
+	for _, wr := range d.HeroSideWR {
+		if !wr.Unsplit && wr.RadiantWinrate != 0 && wr.DireWinrate != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sideLogOdds is the edge of the radiant side, from the mean radiant and
+// dire winrates of the heroes, 0 when they are not split by side.
+func (d *Dataset) sideLogOdds() float64 {
+	var radiant, dire float64
+	n := 0
+	for _, wr := range d.HeroSideWR {
+		if wr.Unsplit || wr.RadiantWinrate == 0 || wr.DireWinrate == 0 {
+			continue
+		}
+		radiant += wr.RadiantWinrate
+		dire += wr.DireWinrate
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return logit(50 + (radiant-dire)/float64(n)/2)
+}
+
+// matchupAdvantage is how many percent points r gains over its baseline
+// against d, averaged with what d loses against r.
+func (d *Dataset) matchupAdvantage(r, dh *Hero) float64 {
+	var total float64
+	n := 0
+	if c := d.CountersMap[r.ID][dh.ID]; c != nil {
+		total += c.Disadvantage
+		n++
+	}
+	if c := d.CountersMap[dh.ID][r.ID]; c != nil {
+		total -= c.Disadvantage
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
+
+// WinRate returns the radiant and dire win probabilities in percent, they
+// sum to 100.
+func (m LogOddsModel) WinRate(d *Dataset, radiant, dire []*Hero) (float64, float64) {
+	x := d.sideLogOdds()
+	for _, h := range radiant {
+		x += m.HeroWeight * logit(d.baseWinRate(h))
+	}
+	for _, h := range dire {
+		x -= m.HeroWeight * logit(d.baseWinRate(h))
+	}
+	for _, r := range radiant {
+		for _, dh := range dire {
+			x += m.MatchupWeight * logit(50+d.matchupAdvantage(r, dh))
+		}
+	}
+	rw := sigmoid(x) * 100
+	return rw, 100 - rw
+}
+
+// LogOddsWinRate scores the draft with DefaultLogOddsModel.
+func (d *Dataset) LogOddsWinRate(radiant, dire []*Hero) (float64, float64) {
+	return DefaultLogOddsModel.WinRate(d, radiant, dire)
+}
@@ -0,0 +1,61 @@
+package dotabuff
+
+import (
+	"testing"
+)
+
+// logOddsDataset has the fixture counters and overall winrates from 46% to
+// 54%, not split by side.
+func logOddsDataset(heroes []*Hero) *Dataset {
+	ds := NewDataset("fixture")
+	ds.Heroes = heroes
+	wrs := make([]*RadiantDireWinrate, 0, len(heroes))
+	for i, hero := range heroes {
+		wr := 46 + float64(i)
+		wrs = append(wrs, &RadiantDireWinrate{Hero: hero, RadiantWinrate: wr, DireWinrate: wr, Unsplit: true})
+	}
+	ds.SetSideWR(wrs)
+	ds.SetCounters(fixtureCounters(heroes))
+	return ds
+}
+
+func TestLogOddsSwapSides(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	ds := logOddsDataset(heroes)
+	radiant := []*Hero{heroes[0], heroes[3], heroes[4], heroes[7], heroes[9]}
+	dire := []*Hero{heroes[1], heroes[2], heroes[5], heroes[6], heroes[8]}
+	rw, dw := DefaultLogOddsModel.WinRate(ds, radiant, dire)
+	if !approxEqual(rw+dw, 100) || approxEqual(rw, 50) {
+		t.Fatalf("got %v/%v", rw, dw)
+	}
+	swappedRadiant, swappedDire := DefaultLogOddsModel.WinRate(ds, dire, radiant)
+	if !approxEqual(swappedRadiant, 100-rw) || !approxEqual(swappedDire, rw) {
+		t.Fatalf("got %v/%v after swapping sides, want %v/%v", swappedRadiant, swappedDire, 100-rw, rw)
+	}
+}
+
+func TestLogOddsEqualHeroes(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	counters := make(map[int][]*Counter, len(heroes))
+	wrs := make([]*RadiantDireWinrate, 0, len(heroes))
+	for _, x := range heroes {
+		for _, y := range heroes {
+			if x != y {
+				counters[x.ID] = append(counters[x.ID], &Counter{Hero: y, WinRate: 50, MatchesPlayed: 100})
+			}
+		}
+		wrs = append(wrs, &RadiantDireWinrate{Hero: x, RadiantWinrate: 52, DireWinrate: 48})
+	}
+	ds := NewDataset("fixture")
+	ds.Heroes = heroes
+	ds.SetSideWR(wrs)
+	ds.SetCounters(counters)
+	rw, dw := DefaultLogOddsModel.WinRate(ds, heroes[:5], heroes[5:])
+	if !approxEqual(rw, 52) || !approxEqual(dw, 48) {
+		t.Fatalf("got %v/%v, want the 52/48 side edge", rw, dw)
+	}
+	rw, _ = DefaultLogOddsModel.WinRate(ds, heroes[5:], heroes[:5])
+	if !approxEqual(rw, 52) {
+		t.Fatalf("got %v after swapping sides, want the 52 side edge", rw)
+	}
+}
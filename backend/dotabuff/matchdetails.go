@@ -0,0 +1,190 @@
+package dotabuff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+// Selectors of the match details on a dotabuff match page. The details
+// are best effort: a missing element leaves the field empty and is only
+// logged, the draft itself is what ParseSide validates.
+const (
+	matchInfoXPath      = "//dl[dt[normalize-space(.) = '%s']]/dd"
+	matchStartXPath     = "//time[@datetime]"
+	playerLinkXPath     = ".//a[starts-with(@href, '/players/')][normalize-space(.) != '']"
+	playerKDAXPath      = "./td[contains(concat(' ', normalize-space(@class), ' '), ' r-group-1 ')]"
+	playerNetWorthXPath = "./td[contains(concat(' ', normalize-space(@class), ' '), ' r-group-2 ')]"
+	playerLevelXPath    = ".//span[contains(concat(' ', normalize-space(@class), ' '), ' overlay-text ')]"
+	playerItemsXPath    = ".//a[starts-with(@href, '/items/')]"
+	playerLaneXPath     = ".//*[contains(concat(' ', normalize-space(@class), ' '), ' lane-icon ')]"
+)
+
+// ParseMatchDetails fills the start time, duration, mode, lobby, region
+// and the players of the match from a dotabuff match page, the positions
+// of the players are derived from their lanes and net worth.
+func ParseMatchDetails(link string, root *html.Node, match *DotabuffMatch) {
+	match.GameMode = matchInfo(root, "Game Mode")
+	match.LobbyType = matchInfo(root, "Lobby Type")
+	match.Region = matchInfo(root, "Region")
+	if d, err := ParseDuration(matchInfo(root, "Duration")); err == nil {
+		match.Duration = d
+	} else {
+		log.Warn().Err(err).Str("url", link).Msg("Match duration not found")
+	}
+	if t := htmlquery.FindOne(root, matchStartXPath); t != nil {
+		start, err := time.Parse(time.RFC3339, htmlquery.SelectAttr(t, "datetime"))
+		if err == nil {
+			match.StartTime = start
+		}
+	}
+	match.Players = make([]*MatchPlayer, 0, 10)
+	for _, side := range []string{"radiant", "dire"} {
+		section := sideSection(root, side)
+		if section == nil {
+			continue
+		}
+		for _, tr := range htmlquery.Find(section, playerRowsXPath) {
+			player, err := ParsePlayerRow(link, tr)
+			if err != nil {
+				log.Warn().Err(err).Str("url", link).Msg("Skipping player row")
+				continue
+			}
+			player.Radiant = side == "radiant"
+			match.Players = append(match.Players, player)
+		}
+	}
+	AssignPositions(match.Players)
+}
+
+func matchInfo(root *html.Node, label string) string {
+	dd := htmlquery.FindOne(root, fmt.Sprintf(matchInfoXPath, label))
+	if dd == nil {
+		return ""
+	}
+	return strings.TrimSpace(htmlquery.InnerText(dd))
+}
+
+// ParseDuration parses "42:17" and "1:02:03".
+func ParseDuration(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("Invalid duration: %q", s)
+	}
+	var total time.Duration
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration: %q", s)
+		}
+		total = total*60 + time.Duration(n)
+	}
+	return total * time.Second, nil
+}
+
+func ParsePlayerRow(link string, tr *html.Node) (*MatchPlayer, error) {
+	hero, err := HeroFromTr(link, tr)
+	if err != nil {
+		return nil, err
+	}
+	player := &MatchPlayer{Hero: hero, Items: make([]string, 0, 6)}
+	if a := htmlquery.FindOne(tr, playerLinkXPath); a != nil {
+		player.Name = strings.TrimSpace(htmlquery.InnerText(a))
+		id := strings.TrimPrefix(htmlquery.SelectAttr(a, "href"), "/players/")
+		player.AccountID, _ = strconv.ParseInt(id, 10, 64)
+	}
+	kda := htmlquery.Find(tr, playerKDAXPath)
+	if len(kda) >= 3 {
+		player.Kills = parseStat(htmlquery.InnerText(kda[0]))
+		player.Deaths = parseStat(htmlquery.InnerText(kda[1]))
+		player.Assists = parseStat(htmlquery.InnerText(kda[2]))
+	}
+	if nw := htmlquery.FindOne(tr, playerNetWorthXPath); nw != nil {
+		player.NetWorth = parseStat(htmlquery.InnerText(nw))
+	}
+	if lvl := htmlquery.FindOne(tr, playerLevelXPath); lvl != nil {
+		player.Level = parseStat(htmlquery.InnerText(lvl))
+	}
+	if lane := htmlquery.FindOne(tr, playerLaneXPath); lane != nil {
+		player.Lane = ParseLane(htmlquery.SelectAttr(lane, "title"))
+	}
+	for _, a := range htmlquery.Find(tr, playerItemsXPath) {
+		player.Items = append(player.Items, itemName(htmlquery.SelectAttr(a, "href")))
+	}
+	return player, nil
+}
+
+// parseStat parses the numbers of the scoreboard, e.g. "12", "1,234" or
+// "24.5k". Unparsable values are zero.
+func parseStat(s string) int {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), ",", ""))
+	mult := 1.0
+	if strings.HasSuffix(s, "k") {
+		mult = 1000
+		s = strings.TrimSuffix(s, "k")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int(v * mult)
+}
+
+// itemName turns "/items/black-king-bar" into "black_king_bar", the item
+// naming used by the OpenDota constants.
+func itemName(href string) string {
+	name := strings.TrimPrefix(href, "/items/")
+	if i := strings.IndexAny(name, "/?#"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+var gameModes = map[int]string{
+	0:  "Unknown",
+	1:  "All Pick",
+	2:  "Captains Mode",
+	3:  "Random Draft",
+	4:  "Single Draft",
+	5:  "All Random",
+	8:  "Reverse Captains Mode",
+	11: "Mid Only",
+	12: "Least Played",
+	16: "Captains Draft",
+	18: "Ability Draft",
+	20: "All Random Deathmatch",
+	21: "1v1 Solo Mid",
+	22: "Ranked All Pick",
+	23: "Turbo",
+}
+
+var lobbyTypes = map[int]string{
+	0: "Normal",
+	1: "Practice",
+	2: "Tournament",
+	4: "Co-op Bots",
+	5: "Ranked Team",
+	6: "Ranked Solo",
+	7: "Ranked Matchmaking",
+	8: "1v1 Solo Mid",
+	9: "Battle Cup",
+}
+
+func GameModeName(id int) string {
+	if name, ok := gameModes[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("Game mode %d", id)
+}
+
+func LobbyTypeName(id int) string {
+	if name, ok := lobbyTypes[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("Lobby type %d", id)
+}
@@ -0,0 +1,173 @@
+package dotabuff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ParseMatchJSON reads match details dumped from OpenDota (/matches/{id})
+// or from the Valve GetMatchDetails API, the latter wraps the match in a
+// "result" object. A JSON array of such matches is accepted too. Heroes
+// come from the registry, so nothing is fetched.
+func ParseMatchJSON(b []byte) ([]*DotabuffMatch, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		var raw []json.RawMessage
+		err := json.Unmarshal(b, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid match list: %v", err)
+		}
+		matches := make([]*DotabuffMatch, 0, len(raw))
+		for i, r := range raw {
+			match, err := parseMatchObject(r)
+			if err != nil {
+				return nil, fmt.Errorf("Match #%d: %v", i+1, err)
+			}
+			matches = append(matches, match)
+		}
+		return matches, nil
+	}
+	match, err := parseMatchObject(b)
+	if err != nil {
+		return nil, err
+	}
+	return []*DotabuffMatch{match}, nil
+}
+
+type valveMatchDetails struct {
+	Result json.RawMessage `json:"result"`
+}
+
+func parseMatchObject(b []byte) (*DotabuffMatch, error) {
+	var valve valveMatchDetails
+	err := json.Unmarshal(b, &valve)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid match details: %v", err)
+	}
+	if len(valve.Result) > 0 {
+		var failed struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(valve.Result, &failed) == nil && failed.Error != "" {
+			return nil, fmt.Errorf("Valve API error: %s", failed.Error)
+		}
+		b = valve.Result
+	}
+	var m openDotaMatch
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid match details: %v", err)
+	}
+	if m.MatchID == 0 {
+		return nil, fmt.Errorf("Match details have no match_id")
+	}
+	return openDotaMatchToMatch(&m, registryHero, nil, nil)
+}
+
+func registryHero(id int) (*Hero, error) {
+	hero, ok := HeroByID(id)
+	if !ok {
+		return nil, fmt.Errorf("Unknown hero id %d", id)
+	}
+	return hero, nil
+}
+
+// ReadMatchFile parses the matches of a local JSON file.
+func ReadMatchFile(path string) ([]*DotabuffMatch, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := ParseMatchJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return matches, nil
+}
+
+// MatchFiles expands the paths into the JSON files to read, directories
+// are walked for *.json files.
+func MatchFiles(paths []string) ([]string, error) {
+	res := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			res = append(res, path)
+			continue
+		}
+		found := make([]string, 0)
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(strings.ToLower(p), ".json") {
+				found = append(found, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		res = append(res, found...)
+	}
+	return res, nil
+}
+
+// MatchFileResult is the prediction of one match read from a file, Err
+// is set when the file or the match could not be scored.
+type MatchFileResult struct {
+	Path       string
+	Match      *DotabuffMatch
+	RadiantWin float64
+	DireWin    float64
+	Err        error
+}
+
+// PredictMatchFiles scores every match of the files and directories with
+// PredictMatch, the predictions are only stored in MySQL with store set. A
+// broken file does not stop the run, its error is reported in the results.
+func (e *Engine) PredictMatchFiles(paths []string, opts PickOptions, store bool) ([]*MatchFileResult, error) {
+	if store && e.mysql == nil {
+		return nil, fmt.Errorf("Storing the predictions of match files needs MySQL")
+	}
+	files, err := MatchFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*MatchFileResult, 0, len(files))
+	for _, file := range files {
+		matches, err := ReadMatchFile(file)
+		if err != nil {
+			log.Warn().Err(err).Str("file", file).Msg("Skipping match file")
+			results = append(results, &MatchFileResult{Path: file, Err: err})
+			continue
+		}
+		for _, match := range matches {
+			res := &MatchFileResult{Path: file, Match: match}
+			p, err := e.PredictMatch(match, opts)
+			if err == nil {
+				res.RadiantWin, res.DireWin = p.RadiantWin, p.DireWin
+				if store {
+					err = e.mysql.InsertDotabuffMatchPredictions(match, e.storedPredictions(match, opts, p))
+				}
+			}
+			if err != nil {
+				log.Warn().Err(err).Str("file", file).Int64("match", match.MatchID).Msg("Error predicting match")
+				res.Err = err
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,33 @@
+package dotabuff
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPredictMatchFiles(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, fixtureCounters(heroes)))
+	err := e.LoadHeroes()
+	if err == nil {
+		err = e.LoadCounters()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", "opendota", "matches_7000000001.json")
+	results, err := e.PredictMatchFiles([]string{path}, PickOptions{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("got %+v", results)
+	}
+	if res := results[0]; res.Match.MatchID != 7000000001 || !approxEqual(res.RadiantWin, 45) || !approxEqual(res.DireWin, 55) {
+		t.Fatalf("got %+v", res)
+	}
+	_, err = e.PredictMatchFiles([]string{path}, PickOptions{}, true)
+	if err == nil {
+		t.Fatal("storing without MySQL did not fail")
+	}
+}
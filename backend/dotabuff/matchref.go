@@ -0,0 +1,80 @@
+package dotabuff
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// matchRefHosts are the sites whose match pages we accept, subdomains
+// such as www. are allowed.
+var matchRefHosts = []string{"dotabuff.com", "opendota.com", "stratz.com"}
+
+// ParseMatchRef normalizes whatever users paste as a match into its ID:
+//
+//	7412345678
+//	https://www.dotabuff.com/matches/7412345678
+//	dotabuff.com/matches/7412345678/builds?x=1
+//	http://opendota.com/matches/7412345678
+//	https://stratz.com/matches/7412345678/breakdown
+func ParseMatchRef(ref string) (int64, error) {
+	ref = strings.TrimSpace(ref)
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		if id <= 0 {
+			return 0, fmt.Errorf("Invalid match id: %s", ref)
+		}
+		return id, nil
+	}
+	if !strings.Contains(ref, "://") {
+		ref = "https://" + ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid match link: %s", ref)
+	}
+	if !isMatchRefHost(u.Hostname()) {
+		return 0, fmt.Errorf("Unsupported match link: %s, use a dotabuff, opendota or stratz link", ref)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] != "matches" && parts[i] != "match" {
+			continue
+		}
+		id, err := strconv.ParseInt(parts[i+1], 10, 64)
+		if err != nil || id <= 0 {
+			return 0, fmt.Errorf("Invalid match id in link: %s", ref)
+		}
+		return id, nil
+	}
+	return 0, fmt.Errorf("No match id in link: %s", ref)
+}
+
+func isMatchRefHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range matchRefHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// LooksLikeMatchRef tells whether the text is meant as a match reference,
+// a bare number or a link to one of the supported sites, so that a broken
+// link gets an error instead of being ignored.
+func LooksLikeMatchRef(text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(text, " \n,") {
+		return false
+	}
+	for _, h := range matchRefHosts {
+		if strings.Contains(text, h+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package dotabuff
+
+import "testing"
+
+func TestParseMatchRef(t *testing.T) {
+	tests := []struct {
+		ref string
+		id  int64
+	}{
+		{"7412345678", 7412345678},
+		{"  7412345678\n", 7412345678},
+		{"https://www.dotabuff.com/matches/7412345678", 7412345678},
+		{"http://dotabuff.com/matches/7412345678", 7412345678},
+		{"dotabuff.com/matches/7412345678/builds?x=1", 7412345678},
+		{"www.dotabuff.com/matches/7412345678/", 7412345678},
+		{"https://www.opendota.com/matches/7412345678", 7412345678},
+		{"opendota.com/matches/7412345678/overview", 7412345678},
+		{"https://stratz.com/matches/7412345678/breakdown", 7412345678},
+		{"https://STRATZ.com/match/7412345678", 7412345678},
+		{"https://www.dotabuff.com/matches/7412345678#draft", 7412345678},
+	}
+	for _, tt := range tests {
+		id, err := ParseMatchRef(tt.ref)
+		if err != nil || id != tt.id {
+			t.Errorf("%q: got %d, %v, want %d", tt.ref, id, err, tt.id)
+		}
+	}
+}
+
+func TestParseMatchRefRejected(t *testing.T) {
+	for _, ref := range []string{
+		"",
+		"0",
+		"-7412345678",
+		"99999999999999999999",
+		"https://www.dotabuff.com/matches/99999999999999999999",
+		"https://example.com/matches/7412345678",
+		"https://notdotabuff.com/matches/7412345678",
+		"https://dotabuff.com.example.com/matches/7412345678",
+		"https://www.dotabuff.com/matches/abc",
+		"https://www.dotabuff.com/matches/0",
+		"https://www.dotabuff.com/players/123",
+		"https://www.dotabuff.com/matches",
+		"axe, bane",
+	} {
+		if id, err := ParseMatchRef(ref); err == nil {
+			t.Errorf("%q: got %d, want an error", ref, id)
+		}
+	}
+}
+
+func TestLooksLikeMatchRef(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"7412345678", true},
+		{" 7412345678 ", true},
+		{"https://www.dotabuff.com/matches/7412345678", true},
+		{"dotabuff.com/matches/abc", true},
+		{"OpenDota.com/matches/7412345678", true},
+		{"stratz.com/match/7412345678", true},
+		{"", false},
+		{"hello", false},
+		{"/team 2163", false},
+		{"https://example.com/matches/7412345678", false},
+		{"look at dotabuff.com/matches/7412345678", false},
+		{"am,axe,bane,bs,cm,drow,es,jugg,mirana,morph", false},
+	}
+	for _, tt := range tests {
+		if got := LooksLikeMatchRef(tt.text); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+-- Upgrades a dotabuff_match table created from an older ddl.sql. Apply the
+-- statements added after your last upgrade, in order, e.g.:
+--
+--   mysql -h <host> -u <user> -p <database> < dotabuff/migrate.sql
+--
+-- MySQL fails on columns that already exist, so on a partially upgraded
+-- database run only the missing statements. The other tables are new, create
+-- them from ddl.sql.
+
+-- hero IDs of both sides
+ALTER TABLE dotabuff_match ADD COLUMN dire_hero_ids text AFTER radiant_heroes;
+ALTER TABLE dotabuff_match ADD COLUMN radiant_hero_ids text AFTER dire_hero_ids;
+
+-- patch of the data the match was scored with
+ALTER TABLE dotabuff_match ADD COLUMN patch varchar(32) AFTER algorithm_version;
+
+-- counter filter the match was scored with
+ALTER TABLE dotabuff_match ADD COLUMN counter_filter varchar(64) AFTER patch;
+
+-- match details
+ALTER TABLE dotabuff_match ADD COLUMN start_time datetime AFTER counter_filter;
+ALTER TABLE dotabuff_match ADD COLUMN duration_seconds int AFTER start_time;
+ALTER TABLE dotabuff_match ADD COLUMN game_mode varchar(64) AFTER duration_seconds;
+ALTER TABLE dotabuff_match ADD COLUMN lobby_type varchar(64) AFTER game_mode;
+ALTER TABLE dotabuff_match ADD COLUMN region varchar(64) AFTER lobby_type;
+
+-- when the prediction was made, older rows stay NULL
+ALTER TABLE dotabuff_match ADD COLUMN predicted_at datetime AFTER region;
+
+-- raw scores the win probabilities were normalized from
+ALTER TABLE dotabuff_match ADD COLUMN raw_radiant_win_prediction float AFTER dire_win_prediction;
+ALTER TABLE dotabuff_match ADD COLUMN raw_dire_win_prediction float AFTER raw_radiant_win_prediction;
@@ -2,7 +2,9 @@ package dotabuff
 
 import (
 	"database/sql"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/rs/zerolog/log"
@@ -25,15 +27,50 @@ func NewMySQL(conn string) (*MySQL, error) {
 	return &MySQL{db: db}, nil
 }
 
-func (m *MySQL) InsertDotabuffMatch(match *DotabuffMatch, algorithmVersion string, radiantWinPrediction float64, direWinPrediction float64) error {
+// Prediction is a scored draft together with the data it was scored with.
+// RadiantWin and DireWin are win probabilities in percent that sum to 100,
+// the raw scores they were normalized from are kept for diagnostics.
+type Prediction struct {
+	AlgorithmVersion string
+	Patch            string
+	CounterFilter    string
+	RadiantWin       float64
+	DireWin          float64
+	RawRadiantWin    float64
+	RawDireWin       float64
+}
+
+func (m *MySQL) InsertDotabuffMatch(match *DotabuffMatch, p *Prediction) error {
+	return m.InsertDotabuffMatchPredictions(match, []*Prediction{p})
+}
+
+// InsertDotabuffMatchPredictions stores a row of the match per prediction,
+// one per algorithm version, and the players and the draft once.
+func (m *MySQL) InsertDotabuffMatchPredictions(match *DotabuffMatch, predictions []*Prediction) error {
 	if m.db == nil {
 		return nil
 	}
-	radiantWinPredictionBool := radiantWinPrediction > direWinPrediction
+	for _, p := range predictions {
+		err := m.insertMatchPrediction(match, p)
+		if err != nil {
+			return err
+		}
+	}
+	err := m.insertMatchPlayers(match)
+	if err != nil {
+		return err
+	}
+	return m.insertMatchDraft(match)
+}
+
+func (m *MySQL) insertMatchPrediction(match *DotabuffMatch, p *Prediction) error {
+	radiantWinPredictionBool := p.RadiantWin > p.DireWin
 	queryMultiline:= `INSERT IGNORE INTO dotabuff_match (
 		id,
 		dire_heroes,
 		radiant_heroes,
+		dire_hero_ids,
+		radiant_hero_ids,
 		radiant_won,
 		radiant_won_prediction,
 		tournament_link,
@@ -41,32 +78,332 @@ func (m *MySQL) InsertDotabuffMatch(match *DotabuffMatch, algorithmVersion strin
 		radiant_team_link,
 		radiant_win_prediction,
 		dire_win_prediction,
-		algorithm_version
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		raw_radiant_win_prediction,
+		raw_dire_win_prediction,
+		algorithm_version,
+		patch,
+		counter_filter,
+		start_time,
+		duration_seconds,
+		game_mode,
+		lobby_type,
+		region,
+		predicted_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	query := strings.ReplaceAll(queryMultiline, "\n", "")
 	_, err := m.db.Exec(query,
 		match.MatchID,
 		heroesToString(match.Dire),
 		heroesToString(match.Radiant),
+		heroIDsToString(match.Dire),
+		heroIDsToString(match.Radiant),
 		match.RadiantWon,
 		radiantWinPredictionBool,
 		match.TournamentLink,
 		match.DireTeam.Link,
 		match.RadiantTeam.Link,
-		radiantWinPrediction,
-		direWinPrediction,
-		algorithmVersion,
+		p.RadiantWin,
+		p.DireWin,
+		p.RawRadiantWin,
+		p.RawDireWin,
+		p.AlgorithmVersion,
+		p.Patch,
+		p.CounterFilter,
+		sql.NullTime{Time: match.StartTime, Valid: !match.StartTime.IsZero()},
+		int64(match.Duration.Seconds()),
+		match.GameMode,
+		match.LobbyType,
+		match.Region,
+		time.Now().UTC(),
 	)
 	if err != nil {
-		log.Error().Err(err).Msg("Error inserting dotabuff match")
+		log.Error().Err(err).Str("algorithm", p.AlgorithmVersion).Msg("Error inserting dotabuff match")
 	}
 	return err
 }
 
+func (m *MySQL) insertMatchDraft(match *DotabuffMatch) error {
+	query := `INSERT IGNORE INTO dotabuff_match_draft (match_id, draft_order, is_pick, is_radiant, hero_id) VALUES (?, ?, ?, ?, ?)`
+	for _, a := range match.Draft {
+		_, err := m.db.Exec(query, match.MatchID, a.Order, a.Pick, a.Radiant, a.Hero.ID)
+		if err != nil {
+			log.Error().Err(err).Int64("match", match.MatchID).Msg("Error inserting draft action")
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MySQL) insertMatchPlayers(match *DotabuffMatch) error {
+	query := `INSERT IGNORE INTO dotabuff_match_player (
+		match_id, hero_id, account_id, player_name, is_radiant, kills, deaths, assists, net_worth, level, items, lane, position
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, p := range match.Players {
+		_, err := m.db.Exec(query,
+			match.MatchID,
+			p.Hero.ID,
+			p.AccountID,
+			p.Name,
+			p.Radiant,
+			p.Kills,
+			p.Deaths,
+			p.Assists,
+			p.NetWorth,
+			p.Level,
+			strings.Join(p.Items, " "),
+			p.Lane,
+			p.Position,
+		)
+		if err != nil {
+			log.Error().Err(err).Int64("match", match.MatchID).Msg("Error inserting match player")
+			return err
+		}
+	}
+	return nil
+}
+
 func heroesToString(heroes []*Hero) string {
 	names := make([]string, 0)
 	for _, hero := range heroes {
 		names = append(names, hero.Name)
 	}
 	return strings.Join(names, " ")
-}
\ No newline at end of file
+}
+
+func heroIDsToString(heroes []*Hero) string {
+	ids := make([]string, 0, len(heroes))
+	for _, hero := range heroes {
+		ids = append(ids, strconv.Itoa(hero.ID))
+	}
+	return strings.Join(ids, " ")
+}
+
+// ProMatches returns the drafts and results of the stored tournament
+// matches played in [from, to), a zero time leaves that side open and
+// matches without a start time are only returned when both are zero.
+// Matches stored before the hero IDs were kept are skipped.
+func (m *MySQL) ProMatches(from, to time.Time) ([]*ProMatch, error) {
+	query := `SELECT DISTINCT id, radiant_hero_ids, dire_hero_ids, radiant_won FROM dotabuff_match
+		WHERE radiant_hero_ids IS NOT NULL AND dire_hero_ids IS NOT NULL AND tournament_link LIKE '%/esports/leagues/%'`
+	args := []any{}
+	if !from.IsZero() {
+		query += " AND start_time >= FROM_UNIXTIME(?)"
+		args = append(args, from.Unix())
+	}
+	if !to.IsZero() {
+		query += " AND start_time < FROM_UNIXTIME(?)"
+		args = append(args, to.Unix())
+	}
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("Error querying pro matches")
+		return nil, err
+	}
+	defer rows.Close()
+	seen := make(map[int64]bool)
+	res := make([]*ProMatch, 0)
+	for rows.Next() {
+		var radiant, dire string
+		match := &ProMatch{}
+		err = rows.Scan(&match.MatchID, &radiant, &dire, &match.RadiantWon)
+		if err != nil {
+			return nil, err
+		}
+		if seen[match.MatchID] {
+			continue
+		}
+		seen[match.MatchID] = true
+		match.Radiant, err = stringToHeroIDs(radiant)
+		if err != nil {
+			log.Warn().Err(err).Int64("match", match.MatchID).Msg("Skipping match with broken hero ids")
+			continue
+		}
+		match.Dire, err = stringToHeroIDs(dire)
+		if err != nil {
+			log.Warn().Err(err).Int64("match", match.MatchID).Msg("Skipping match with broken hero ids")
+			continue
+		}
+		res = append(res, match)
+	}
+	return res, rows.Err()
+}
+
+// BacktestMatches returns the stored matches with their heroes, result,
+// tournament and start time, once per match whatever the number of
+// algorithm versions that predicted it.
+func (m *MySQL) BacktestMatches() ([]*BacktestMatch, error) {
+	query := `SELECT id, radiant_hero_ids, dire_hero_ids, radiant_won, tournament_link, patch, UNIX_TIMESTAMP(start_time)
+		FROM dotabuff_match WHERE radiant_hero_ids IS NOT NULL AND dire_hero_ids IS NOT NULL ORDER BY id`
+	rows, err := m.db.Query(query)
+	if err != nil {
+		log.Error().Err(err).Msg("Error querying backtest matches")
+		return nil, err
+	}
+	defer rows.Close()
+	seen := make(map[int64]bool)
+	res := make([]*BacktestMatch, 0)
+	for rows.Next() {
+		var radiant, dire string
+		var tournament, patch sql.NullString
+		var startTime sql.NullInt64
+		match := &DotabuffMatch{}
+		err = rows.Scan(&match.MatchID, &radiant, &dire, &match.RadiantWon, &tournament, &patch, &startTime)
+		if err != nil {
+			return nil, err
+		}
+		if seen[match.MatchID] {
+			continue
+		}
+		seen[match.MatchID] = true
+		match.Radiant, err = heroIDsToHeroes(radiant)
+		if err == nil {
+			match.Dire, err = heroIDsToHeroes(dire)
+		}
+		if err != nil {
+			log.Warn().Err(err).Int64("match", match.MatchID).Msg("Skipping match with broken hero ids")
+			continue
+		}
+		match.TournamentLink = tournament.String
+		if startTime.Valid && startTime.Int64 > 0 {
+			match.StartTime = time.Unix(startTime.Int64, 0).UTC()
+		}
+		res = append(res, &BacktestMatch{Match: match, Patch: patch.String})
+	}
+	return res, rows.Err()
+}
+
+// StoredPredictions returns every stored prediction of a finished match,
+// one per algorithm version.
+func (m *MySQL) StoredPredictions() ([]*StoredPrediction, error) {
+	query := `SELECT id, algorithm_version, radiant_win_prediction, dire_win_prediction, radiant_won, UNIX_TIMESTAMP(predicted_at)
+		FROM dotabuff_match WHERE radiant_win_prediction IS NOT NULL AND dire_win_prediction IS NOT NULL AND radiant_won IS NOT NULL`
+	rows, err := m.db.Query(query)
+	if err != nil {
+		log.Error().Err(err).Msg("Error querying stored predictions")
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]*StoredPrediction, 0)
+	for rows.Next() {
+		var version sql.NullString
+		var predictedAt sql.NullInt64
+		p := &StoredPrediction{}
+		err = rows.Scan(&p.MatchID, &version, &p.RadiantWin, &p.DireWin, &p.RadiantWon, &predictedAt)
+		if err != nil {
+			return nil, err
+		}
+		p.AlgorithmVersion = version.String
+		if predictedAt.Valid && predictedAt.Int64 > 0 {
+			p.PredictedAt = time.Unix(predictedAt.Int64, 0).UTC()
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+func heroIDsToHeroes(s string) ([]*Hero, error) {
+	ids, err := stringToHeroIDs(s)
+	if err != nil {
+		return nil, err
+	}
+	heroes := make([]*Hero, 0, len(ids))
+	for _, id := range ids {
+		hero, err := registryHero(id)
+		if err != nil {
+			return nil, err
+		}
+		heroes = append(heroes, hero)
+	}
+	return heroes, nil
+}
+
+func stringToHeroIDs(s string) ([]int, error) {
+	fields := strings.Fields(s)
+	ids := make([]int, 0, len(fields))
+	for _, f := range fields {
+		id, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// InsertTeam stores the team profile, the roster and the hero pool replace
+// the previously stored ones.
+func (m *MySQL) InsertTeam(team *TeamProfile) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = insertTeam(tx, team)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Int64("team", team.ID).Msg("Error inserting team")
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertTeam(tx *sql.Tx, team *TeamProfile) error {
+	_, err := tx.Exec(`REPLACE INTO team (id, name, link, fetched_at) VALUES (?, ?, ?, ?)`,
+		team.ID, team.Name, team.Link, team.FetchedAt)
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"team_player", "team_hero"} {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE team_id = ?", team.ID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range team.Roster {
+		_, err = tx.Exec(`INSERT IGNORE INTO team_player (team_id, account_id, name) VALUES (?, ?, ?)`,
+			team.ID, p.AccountID, p.Name)
+		if err != nil {
+			return err
+		}
+	}
+	for _, h := range team.HeroPool {
+		_, err = tx.Exec(`INSERT IGNORE INTO team_hero (team_id, hero_id, matches, win_rate) VALUES (?, ?, ?, ?)`,
+			team.ID, h.Hero.ID, h.Matches, h.WinRate)
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range team.Results {
+		_, err = tx.Exec(`INSERT IGNORE INTO team_result (team_id, match_id, won, opponent) VALUES (?, ?, ?, ?)`,
+			team.ID, r.MatchID, r.Won, r.Opponent)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MySQL) InsertLivePrediction(lp *LivePrediction) error {
+	query := `INSERT IGNORE INTO live_prediction (
+		match_id, algorithm_version, league_id, radiant_team, dire_team, radiant_hero_ids, dire_hero_ids,
+		radiant_win_prediction, dire_win_prediction, patch, counter_filter, predicted_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	p := lp.Prediction
+	_, err := m.db.Exec(query,
+		lp.Match.MatchID,
+		p.AlgorithmVersion,
+		lp.Match.LeagueID,
+		lp.Match.RadiantTeam.Name,
+		lp.Match.DireTeam.Name,
+		heroIDsToString(lp.Match.Radiant),
+		heroIDsToString(lp.Match.Dire),
+		p.RadiantWin,
+		p.DireWin,
+		p.Patch,
+		p.CounterFilter,
+		lp.PredictedAt,
+	)
+	if err != nil {
+		log.Error().Err(err).Int64("match", lp.Match.MatchID).Msg("Error inserting live prediction")
+	}
+	return err
+}
@@ -0,0 +1,621 @@
+package dotabuff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const OpenDotaBaseURL = "https://api.opendota.com/api"
+
+// OpenDotaSource builds the engine data from the OpenDota JSON API
+// instead of scraping dotabuff pages.
+type OpenDotaSource struct {
+	BaseURL string
+	Fetcher *Fetcher
+
+	lock     sync.Mutex
+	heroes   map[int]*Hero
+	baseWR   map[int]float64
+	heroList []*Hero
+	// baseWRLock makes the counter workers share one /heroStats call
+	baseWRLock sync.Mutex
+	// item names and regions by ID, from the OpenDota constants
+	itemNames map[string]string
+	regions   map[string]string
+}
+
+type openDotaHero struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	LocalizedName string `json:"localized_name"`
+}
+
+// openDotaHeroStats holds the per bracket picks and wins, the JSON keys
+// are "1_pick", "1_win" ... "8_pick", "8_win".
+type openDotaHeroStats map[string]any
+
+type openDotaMatchup struct {
+	HeroID      int   `json:"hero_id"`
+	GamesPlayed int64 `json:"games_played"`
+	Wins        int64 `json:"wins"`
+}
+
+type openDotaTeam struct {
+	TeamID int64  `json:"team_id"`
+	Name   string `json:"name"`
+}
+
+type openDotaPlayer struct {
+	HeroID      int    `json:"hero_id"`
+	PlayerSlot  int    `json:"player_slot"`
+	AccountID   int64  `json:"account_id"`
+	Name        string `json:"name"`
+	Personaname string `json:"personaname"`
+	Kills       int    `json:"kills"`
+	Deaths      int    `json:"deaths"`
+	Assists     int    `json:"assists"`
+	NetWorth    int    `json:"net_worth"`
+	Level       int    `json:"level"`
+	Item0       int    `json:"item_0"`
+	Item1       int    `json:"item_1"`
+	Item2       int    `json:"item_2"`
+	Item3       int    `json:"item_3"`
+	Item4       int    `json:"item_4"`
+	Item5       int    `json:"item_5"`
+	// LaneRole is 1 safe, 2 mid, 3 off and 4 jungle
+	LaneRole  int  `json:"lane_role"`
+	IsRoaming bool `json:"is_roaming"`
+}
+
+type openDotaMatch struct {
+	MatchID     int64         `json:"match_id"`
+	RadiantWin  bool          `json:"radiant_win"`
+	LeagueID    int64         `json:"leagueid"`
+	RadiantTeam *openDotaTeam `json:"radiant_team"`
+	DireTeam    *openDotaTeam `json:"dire_team"`
+	RadiantName string        `json:"radiant_name"`
+	DireName    string        `json:"dire_name"`
+	// RadiantTeamID and DireTeamID are set by the Valve API instead of
+	// the team objects
+	RadiantTeamID int64             `json:"radiant_team_id"`
+	DireTeamID    int64             `json:"dire_team_id"`
+	Players       []openDotaPlayer  `json:"players"`
+	StartTime     int64             `json:"start_time"`
+	Duration      int64             `json:"duration"`
+	GameMode      int               `json:"game_mode"`
+	LobbyType     int               `json:"lobby_type"`
+	Region        int               `json:"region"`
+	PicksBans     []openDotaPickBan `json:"picks_bans"`
+}
+
+type openDotaPickBan struct {
+	IsPick bool `json:"is_pick"`
+	HeroID int  `json:"hero_id"`
+	// Team is 0 for radiant and 1 for dire
+	Team  int `json:"team"`
+	Order int `json:"order"`
+}
+
+func NewOpenDotaSource(baseURL string) *OpenDotaSource {
+	if baseURL == "" {
+		baseURL = OpenDotaBaseURL
+	}
+	return &OpenDotaSource{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Fetcher: DefaultFetcher,
+	}
+}
+
+func (o *OpenDotaSource) HeroesURL() string {
+	return o.BaseURL + "/heroes"
+}
+
+func (o *OpenDotaSource) SideWinratesURL() string {
+	return o.BaseURL + "/heroStats"
+}
+
+func (o *OpenDotaSource) CountersURL(hero *Hero, filter CounterFilter) string {
+	return fmt.Sprintf("%s/heroes/%d/matchups", o.BaseURL, hero.ID)
+}
+
+func (o *OpenDotaSource) getJSON(path string, v any) error {
+	return o.Fetcher.GetJSON(o.BaseURL+path, v)
+}
+
+func (o *OpenDotaSource) loadHeroes() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.heroes != nil {
+		return nil
+	}
+	var raw []openDotaHero
+	err := o.getJSON("/heroes", &raw)
+	if err != nil {
+		return err
+	}
+	heroes := make(map[int]*Hero, len(raw))
+	list := make([]*Hero, 0, len(raw))
+	for _, h := range raw {
+		hero := openDotaHeroToHero(h)
+		heroes[h.ID] = hero
+		list = append(list, hero)
+	}
+	o.heroes = heroes
+	o.heroList = list
+	return nil
+}
+
+func openDotaHeroToHero(h openDotaHero) *Hero {
+	if hero, ok := HeroByID(h.ID); ok {
+		return hero
+	}
+	log.Warn().Int("id", h.ID).Str("hero", h.LocalizedName).Msg("Hero is missing from the registry")
+	slug := HeroSlug(h.LocalizedName)
+	return &Hero{
+		ID:      h.ID,
+		NpcName: h.Name,
+		Slug:    slug,
+		Name:    h.LocalizedName,
+		Link:    fmt.Sprintf("https://www.dotabuff.com/heroes/%s", slug),
+	}
+}
+
+func (o *OpenDotaSource) hero(id int) (*Hero, error) {
+	err := o.loadHeroes()
+	if err != nil {
+		return nil, err
+	}
+	hero, ok := o.heroes[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown opendota hero id %d", id)
+	}
+	return hero, nil
+}
+
+func (o *OpenDotaSource) Patch() (string, error) {
+	return LatestPatch(o.Fetcher, o.BaseURL)
+}
+
+func (o *OpenDotaSource) PatchDates() (map[string]time.Time, error) {
+	return PatchDates(o.Fetcher, o.BaseURL)
+}
+
+func (o *OpenDotaSource) Heroes() ([]*Hero, error) {
+	err := o.loadHeroes()
+	if err != nil {
+		return nil, err
+	}
+	return o.heroList, nil
+}
+
+// SideWinrates uses the public matches picks and wins of every bracket.
+// OpenDota does not split them by side, so radiant and dire get the same
+// overall numbers and the winrates are marked Unsplit: they give the base
+// winrate of the heroes but no radiant edge.
+func (o *OpenDotaSource) SideWinrates() ([]*RadiantDireWinrate, error) {
+	stats, err := o.fetchHeroStats()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*RadiantDireWinrate, 0, len(stats))
+	for _, row := range stats {
+		hero, err := o.hero(row.id)
+		if err != nil {
+			log.Warn().Err(err).Msg("Skipping hero stats")
+			continue
+		}
+		res = append(res, &RadiantDireWinrate{
+			Hero:            hero,
+			RadiantWinrate:  row.winRate,
+			RadiantPickRate: row.pickRate,
+			DireWinrate:     row.winRate,
+			DirePickRate:    row.pickRate,
+			Unsplit:         true,
+		})
+	}
+	return res, nil
+}
+
+// openDotaHeroStat is the winrate and pick rate of a hero over every
+// bracket, in percent.
+type openDotaHeroStat struct {
+	id       int
+	winRate  float64
+	pickRate float64
+}
+
+// fetchHeroStats fetches /heroStats and keeps the result for the base
+// winrates of Counters.
+func (o *OpenDotaSource) fetchHeroStats() ([]openDotaHeroStat, error) {
+	var stats []openDotaHeroStats
+	err := o.getJSON("/heroStats", &stats)
+	if err != nil {
+		return nil, err
+	}
+	type pw struct {
+		id          int
+		picks, wins float64
+	}
+	rows := make([]pw, 0, len(stats))
+	var totalPicks float64
+	for _, s := range stats {
+		row := pw{id: int(statNumber(s, "id"))}
+		for bracket := 1; bracket <= 8; bracket++ {
+			row.picks += statNumber(s, fmt.Sprintf("%d_pick", bracket))
+			row.wins += statNumber(s, fmt.Sprintf("%d_win", bracket))
+		}
+		totalPicks += row.picks
+		rows = append(rows, row)
+	}
+	// every match has ten picks
+	totalMatches := totalPicks / 10
+	res := make([]openDotaHeroStat, 0, len(rows))
+	baseWR := make(map[int]float64, len(rows))
+	for _, row := range rows {
+		if row.picks == 0 || totalMatches == 0 {
+			continue
+		}
+		stat := openDotaHeroStat{
+			id:       row.id,
+			winRate:  row.wins / row.picks * 100,
+			pickRate: row.picks / totalMatches * 100,
+		}
+		baseWR[row.id] = stat.winRate
+		res = append(res, stat)
+	}
+	o.lock.Lock()
+	o.baseWR = baseWR
+	o.lock.Unlock()
+	return res, nil
+}
+
+// baseWinRates returns the overall winrates of the heroes, /heroStats is
+// only fetched when SideWinrates has not done it yet, e.g. when the side
+// winrates come from the store or a snapshot.
+func (o *OpenDotaSource) baseWinRates() (map[int]float64, error) {
+	o.baseWRLock.Lock()
+	defer o.baseWRLock.Unlock()
+	o.lock.Lock()
+	baseWR := o.baseWR
+	o.lock.Unlock()
+	if baseWR != nil {
+		return baseWR, nil
+	}
+	_, err := o.fetchHeroStats()
+	if err != nil {
+		return nil, err
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.baseWR, nil
+}
+
+func statNumber(s openDotaHeroStats, key string) float64 {
+	v, ok := s[key].(float64)
+	if !ok {
+		return 0
+	}
+	return v
+}
+
+// Counters converts the matchups of the hero into dotabuff style counters:
+// every counter holds the winrate of the opponent against the hero, and the
+// disadvantage is how much better the opponent does than its own baseline.
+func (o *OpenDotaSource) Counters(hero *Hero, filter CounterFilter) ([]*Counter, error) {
+	if !filter.IsDefault() {
+		return nil, fmt.Errorf("OpenDota matchups can not be filtered by %s", filter)
+	}
+	var matchups []openDotaMatchup
+	err := o.getJSON(fmt.Sprintf("/heroes/%d/matchups", hero.ID), &matchups)
+	if err != nil {
+		return nil, err
+	}
+	baseWR, err := o.baseWinRates()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Counter, 0, len(matchups))
+	for _, m := range matchups {
+		if m.GamesPlayed == 0 {
+			continue
+		}
+		opponent, err := o.hero(m.HeroID)
+		if err != nil {
+			log.Warn().Err(err).Msg("Skipping matchup")
+			continue
+		}
+		winRate := float64(m.GamesPlayed-m.Wins) / float64(m.GamesPlayed) * 100
+		base, ok := baseWR[m.HeroID]
+		if !ok {
+			base = 50
+		}
+		res = append(res, &Counter{
+			Hero:          opponent,
+			Disadvantage:  winRate - base,
+			WinRate:       winRate,
+			MatchesPlayed: m.GamesPlayed,
+		})
+	}
+	return res, nil
+}
+
+func (o *OpenDotaSource) Match(id int64) (*DotabuffMatch, error) {
+	var m openDotaMatch
+	err := o.getJSON(fmt.Sprintf("/matches/%d", id), &m)
+	if err != nil {
+		return nil, err
+	}
+	itemNames, regions := o.constants()
+	return openDotaMatchToMatch(&m, o.hero, itemNames, regions)
+}
+
+// openDotaMatchToMatch converts the match details, hero resolves the hero
+// IDs and the item names and regions may be empty.
+func openDotaMatchToMatch(m *openDotaMatch, hero func(int) (*Hero, error), itemNames map[string]string, regions map[string]string) (*DotabuffMatch, error) {
+	radiant := make([]*Hero, 0, 5)
+	dire := make([]*Hero, 0, 5)
+	players := make([]*MatchPlayer, 0, len(m.Players))
+	for _, p := range m.Players {
+		h, err := hero(p.HeroID)
+		if err != nil {
+			return nil, err
+		}
+		// dire slots start at 128
+		if p.PlayerSlot < 128 {
+			radiant = append(radiant, h)
+		} else {
+			dire = append(dire, h)
+		}
+		players = append(players, openDotaPlayerToPlayer(p, h, itemNames))
+	}
+	if len(radiant) != 5 || len(dire) != 5 {
+		return nil, fmt.Errorf("Match %d has %d radiant and %d dire heroes", m.MatchID, len(radiant), len(dire))
+	}
+	tournamentLink := ""
+	if m.LeagueID != 0 {
+		tournamentLink = fmt.Sprintf("https://www.dotabuff.com/esports/leagues/%d", m.LeagueID)
+	}
+	radiantTeam, direTeam := m.RadiantTeam, m.DireTeam
+	// the Valve API only has the team IDs
+	if radiantTeam == nil && m.RadiantTeamID != 0 {
+		radiantTeam = &openDotaTeam{TeamID: m.RadiantTeamID}
+	}
+	if direTeam == nil && m.DireTeamID != 0 {
+		direTeam = &openDotaTeam{TeamID: m.DireTeamID}
+	}
+	match := &DotabuffMatch{
+		MatchID:        m.MatchID,
+		Dire:           dire,
+		Radiant:        radiant,
+		DireTeam:       openDotaTeamToTeam(direTeam, m.DireName, "Dire"),
+		RadiantTeam:    openDotaTeamToTeam(radiantTeam, m.RadiantName, "Radiant"),
+		RadiantWon:     m.RadiantWin,
+		TournamentLink: tournamentLink,
+		Duration:       time.Duration(m.Duration) * time.Second,
+		GameMode:       GameModeName(m.GameMode),
+		LobbyType:      LobbyTypeName(m.LobbyType),
+		Players:        players,
+	}
+	if m.Region != 0 {
+		match.Region = openDotaRegion(regions, m.Region)
+	}
+	AssignPositions(match.Players)
+	match.Draft = make([]*DraftAction, 0, len(m.PicksBans))
+	for _, pb := range m.PicksBans {
+		h, err := hero(pb.HeroID)
+		if err != nil {
+			return nil, err
+		}
+		// OpenDota orders from 0
+		match.Draft = append(match.Draft, &DraftAction{
+			Order:   pb.Order + 1,
+			Pick:    pb.IsPick,
+			Radiant: pb.Team == 0,
+			Hero:    h,
+		})
+	}
+	if m.StartTime != 0 {
+		match.StartTime = time.Unix(m.StartTime, 0).UTC()
+	}
+	return match, nil
+}
+
+// constants returns the item names and the regions, they are fetched once
+// and the match details go without them when OpenDota fails to serve them.
+func (o *OpenDotaSource) constants() (map[string]string, map[string]string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.itemNames == nil {
+		items := make(map[string]string)
+		err := o.getJSON("/constants/item_ids", &items)
+		if err != nil {
+			log.Warn().Err(err).Msg("Error fetching item names")
+		}
+		o.itemNames = items
+	}
+	if o.regions == nil {
+		regions := make(map[string]string)
+		err := o.getJSON("/constants/region", &regions)
+		if err != nil {
+			log.Warn().Err(err).Msg("Error fetching regions")
+		}
+		o.regions = regions
+	}
+	return o.itemNames, o.regions
+}
+
+func openDotaRegion(regions map[string]string, id int) string {
+	if name, ok := regions[strconv.Itoa(id)]; ok {
+		return name
+	}
+	return fmt.Sprintf("Region %d", id)
+}
+
+func openDotaPlayerToPlayer(p openDotaPlayer, hero *Hero, itemNames map[string]string) *MatchPlayer {
+	name := p.Name
+	if name == "" {
+		name = p.Personaname
+	}
+	items := make([]string, 0, 6)
+	for _, id := range []int{p.Item0, p.Item1, p.Item2, p.Item3, p.Item4, p.Item5} {
+		if id == 0 {
+			continue
+		}
+		item, ok := itemNames[strconv.Itoa(id)]
+		if !ok {
+			item = fmt.Sprintf("item_%d", id)
+		}
+		items = append(items, item)
+	}
+	return &MatchPlayer{
+		AccountID: p.AccountID,
+		Name:      name,
+		Hero:      hero,
+		Radiant:   p.PlayerSlot < 128,
+		Kills:     p.Kills,
+		Deaths:    p.Deaths,
+		Assists:   p.Assists,
+		NetWorth:  p.NetWorth,
+		Level:     p.Level,
+		Items:     items,
+		Lane:      openDotaLane(p),
+	}
+}
+
+func openDotaLane(p openDotaPlayer) string {
+	if p.IsRoaming {
+		return LaneRoaming
+	}
+	switch p.LaneRole {
+	case 1:
+		return LaneSafe
+	case 2:
+		return LaneMid
+	case 3:
+		return LaneOff
+	case 4:
+		return LaneJungle
+	}
+	return ""
+}
+
+func openDotaTeamToTeam(t *openDotaTeam, name string, fallback string) *Team {
+	team := &Team{Name: name}
+	if t != nil {
+		if t.Name != "" {
+			team.Name = t.Name
+		}
+		if t.TeamID != 0 {
+			team.Link = fmt.Sprintf("https://www.dotabuff.com/esports/teams/%d", t.TeamID)
+		}
+	}
+	if team.Name == "" {
+		team.Name = fallback
+	}
+	return team
+}
+
+type openDotaLeagueMatch struct {
+	MatchID int64 `json:"match_id"`
+}
+
+func (o *OpenDotaSource) LeagueMatches(leagueID int64) ([]int64, error) {
+	var matches []openDotaLeagueMatch
+	err := o.getJSON(fmt.Sprintf("/leagues/%d/matches", leagueID), &matches)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(matches))
+	for _, m := range matches {
+		res = append(res, m.MatchID)
+	}
+	return res, nil
+}
+
+type openDotaTeamPlayer struct {
+	AccountID           int64  `json:"account_id"`
+	Name                string `json:"name"`
+	IsCurrentTeamMember bool   `json:"is_current_team_member"`
+}
+
+type openDotaTeamHero struct {
+	HeroID      int `json:"hero_id"`
+	GamesPlayed int `json:"games_played"`
+	Wins        int `json:"wins"`
+}
+
+type openDotaTeamMatch struct {
+	MatchID          int64  `json:"match_id"`
+	RadiantWin       bool   `json:"radiant_win"`
+	Radiant          bool   `json:"radiant"`
+	OpposingTeamName string `json:"opposing_team_name"`
+}
+
+func (o *OpenDotaSource) Team(id int64) (*TeamProfile, error) {
+	var t openDotaTeam
+	err := o.getJSON(fmt.Sprintf("/teams/%d", id), &t)
+	if err != nil {
+		return nil, err
+	}
+	team := &TeamProfile{
+		ID:        id,
+		Name:      t.Name,
+		Link:      fmt.Sprintf("https://www.dotabuff.com/esports/teams/%d", id),
+		Roster:    make([]*TeamPlayer, 0, 5),
+		Results:   make([]*TeamResult, 0, teamRecentResults),
+		HeroPool:  make([]*TeamHero, 0),
+		FetchedAt: time.Now(),
+	}
+	var players []openDotaTeamPlayer
+	err = o.getJSON(fmt.Sprintf("/teams/%d/players", id), &players)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range players {
+		if p.IsCurrentTeamMember {
+			team.Roster = append(team.Roster, &TeamPlayer{AccountID: p.AccountID, Name: p.Name})
+		}
+	}
+	var heroes []openDotaTeamHero
+	err = o.getJSON(fmt.Sprintf("/teams/%d/heroes", id), &heroes)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range heroes {
+		if h.GamesPlayed == 0 {
+			continue
+		}
+		hero, err := o.hero(h.HeroID)
+		if err != nil {
+			log.Warn().Err(err).Msg("Skipping team hero")
+			continue
+		}
+		team.HeroPool = append(team.HeroPool, &TeamHero{
+			Hero:    hero,
+			Matches: h.GamesPlayed,
+			WinRate: float64(h.Wins) / float64(h.GamesPlayed) * 100,
+		})
+	}
+	sortHeroPool(team.HeroPool)
+	var matches []openDotaTeamMatch
+	err = o.getJSON(fmt.Sprintf("/teams/%d/matches", id), &matches)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range matches {
+		if len(team.Results) == teamRecentResults {
+			break
+		}
+		team.Results = append(team.Results, &TeamResult{
+			MatchID:  m.MatchID,
+			Won:      m.Radiant == m.RadiantWin,
+			Opponent: m.OpposingTeamName,
+		})
+	}
+	return team, nil
+}
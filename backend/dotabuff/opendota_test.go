@@ -0,0 +1,160 @@
+package dotabuff
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openDotaTestServer serves testdata/opendota, /api/heroes/1/matchups is
+// heroes_1_matchups.json. It counts the requests of every path.
+type openDotaTestServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	requests map[string]int
+}
+
+func newOpenDotaTestServer(t *testing.T) *openDotaTestServer {
+	t.Helper()
+	s := &openDotaTestServer{requests: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/")
+		s.lock.Lock()
+		s.requests[path]++
+		s.lock.Unlock()
+		body, err := os.ReadFile(filepath.Join("testdata", "opendota", strings.ReplaceAll(path, "/", "_")+".json"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *openDotaTestServer) count(path string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests[path]
+}
+
+func (s *openDotaTestServer) source() *OpenDotaSource {
+	o := NewOpenDotaSource(s.URL + "/api")
+	o.Fetcher = NewFetcher(5*time.Second, 0)
+	return o
+}
+
+func TestOpenDotaHeroes(t *testing.T) {
+	s := newOpenDotaTestServer(t)
+	heroes, err := s.source().Heroes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heroes) != 10 {
+		t.Fatalf("got %d heroes, want 10", len(heroes))
+	}
+	if heroes[0].ID != 1 || heroes[0].Name != "Anti-Mage" || heroes[0].Slug != "anti-mage" {
+		t.Fatalf("got %+v", heroes[0])
+	}
+	if hero, _ := HeroByID(1); heroes[0] != hero {
+		t.Fatal("hero is not the registry one")
+	}
+}
+
+func TestOpenDotaSideWinrates(t *testing.T) {
+	s := newOpenDotaTestServer(t)
+	wrs, err := s.source().SideWinrates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wrs) != 10 {
+		t.Fatalf("got %d winrates, want 10", len(wrs))
+	}
+	am := wrs[0]
+	if am.Hero.ID != 1 || !approxEqual(am.RadiantWinrate, 60) || !approxEqual(am.RadiantPickRate, 100) {
+		t.Fatalf("got %+v", am)
+	}
+	if !am.Unsplit || am.DireWinrate != am.RadiantWinrate {
+		t.Fatalf("winrates are not marked unsplit: %+v", am)
+	}
+	ds := NewDataset("opendota")
+	ds.SetSideWR(wrs)
+	if ds.HasSideSplit() || ds.sideLogOdds() != 0 {
+		t.Fatal("unsplit winrates give a side edge")
+	}
+}
+
+func TestOpenDotaCounters(t *testing.T) {
+	s := newOpenDotaTestServer(t)
+	o := s.source()
+	am, _ := HeroByID(1)
+	// without SideWinrates, as with side winrates from the store
+	for i := 0; i < 2; i++ {
+		counters, err := o.Counters(am, CounterFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(counters) != 2 {
+			t.Fatalf("got %d counters, want 2", len(counters))
+		}
+		axe, bane := counters[0], counters[1]
+		if axe.Hero.ID != 2 || !approxEqual(axe.WinRate, 30) || !approxEqual(axe.Disadvantage, -10) || axe.MatchesPlayed != 10 {
+			t.Fatalf("got %+v", axe)
+		}
+		if bane.Hero.ID != 3 || !approxEqual(bane.WinRate, 50) || !approxEqual(bane.Disadvantage, 0) {
+			t.Fatalf("got %+v", bane)
+		}
+	}
+	if n := s.count("heroStats"); n != 1 {
+		t.Fatalf("heroStats fetched %d times, want once", n)
+	}
+	_, err := o.Counters(am, CounterFilter{Bracket: "legend"})
+	if err == nil {
+		t.Fatal("filtered counters did not fail")
+	}
+}
+
+func TestOpenDotaMatch(t *testing.T) {
+	s := newOpenDotaTestServer(t)
+	o := s.source()
+	match, err := o.Match(7000000001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(match.Radiant) != 5 || len(match.Dire) != 5 || match.Radiant[0].ID != 1 || match.Dire[0].ID != 6 {
+		t.Fatalf("got radiant %v and dire %v", match.Radiant, match.Dire)
+	}
+	if !match.RadiantWon || match.RadiantTeam.Name != "Tundra Esports" || match.DireTeam.Name != "Team Liquid" {
+		t.Fatalf("got %+v", match)
+	}
+	if match.TournamentLink != "https://www.dotabuff.com/esports/leagues/16935" {
+		t.Fatalf("got tournament %s", match.TournamentLink)
+	}
+	if match.Duration != 40*time.Minute || match.GameMode != "Captains Mode" || match.LobbyType != "Practice" || match.Region != "EUROPE" {
+		t.Fatalf("got details %v %s %s %s", match.Duration, match.GameMode, match.LobbyType, match.Region)
+	}
+	if !match.StartTime.Equal(time.Unix(1718000000, 0)) {
+		t.Fatalf("got start time %v", match.StartTime)
+	}
+	carry := match.Players[0]
+	if carry.Name != "player0" || len(carry.Items) != 2 || carry.Items[0] != "black_king_bar" || carry.Lane != LaneSafe {
+		t.Fatalf("got %+v", carry)
+	}
+	if match.Players[9].Name != "anon" {
+		t.Fatalf("got %q, want the persona name", match.Players[9].Name)
+	}
+	if len(match.Draft) != 3 || match.Draft[0].Order != 1 || match.Draft[0].Pick || !match.Draft[0].Radiant || match.Draft[2].Radiant {
+		t.Fatalf("got draft %+v", match.Draft)
+	}
+	_, err = o.Match(1)
+	if !IsNotFound(err) {
+		t.Fatalf("got %v, want not found", err)
+	}
+}
@@ -0,0 +1,298 @@
+package dotabuff
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// UnknownPatch is used when the current patch can not be detected.
+const UnknownPatch = "unknown"
+
+type openDotaPatch struct {
+	ID   int       `json:"id"`
+	Name string    `json:"name"`
+	Date time.Time `json:"date"`
+}
+
+func openDotaPatches(fetcher *Fetcher, baseURL string) ([]openDotaPatch, error) {
+	var patches []openDotaPatch
+	err := fetcher.GetJSON(strings.TrimSuffix(baseURL, "/")+"/constants/patch", &patches)
+	if err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("No patches found")
+	}
+	return patches, nil
+}
+
+// PatchDates returns the release date of every patch listed by the
+// OpenDota constants.
+func PatchDates(fetcher *Fetcher, baseURL string) (map[string]time.Time, error) {
+	patches, err := openDotaPatches(fetcher, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	dates := make(map[string]time.Time, len(patches))
+	for _, p := range patches {
+		if !p.Date.IsZero() {
+			dates[p.Name] = p.Date
+		}
+	}
+	return dates, nil
+}
+
+// LatestPatch returns the newest patch listed by the OpenDota constants,
+// e.g. "7.37".
+func LatestPatch(fetcher *Fetcher, baseURL string) (string, error) {
+	patches, err := openDotaPatches(fetcher, baseURL)
+	if err != nil {
+		return "", err
+	}
+	latest := patches[0]
+	for _, p := range patches {
+		if p.ID > latest.ID {
+			latest = p
+		}
+	}
+	return latest.Name, nil
+}
+
+var patchNameRe = regexp.MustCompile(`^[0-9A-Za-z._-]+$`)
+
+func validPatch(patch string) error {
+	if !patchNameRe.MatchString(patch) || strings.Contains(patch, "..") {
+		return fmt.Errorf("Invalid patch name: %q", patch)
+	}
+	return nil
+}
+
+// TTLs of the cached patch data, heroes do not change within a patch.
+const (
+	HeroesTTL     = 0
+	SideWRTTL     = 24 * time.Hour
+	CountersTTL   = 24 * time.Hour
+	PatchDatesTTL = 24 * time.Hour
+)
+
+// patchDatesKey holds the release dates of the patches, it is not the
+// data of a patch.
+const patchDatesKey = "meta/patch_dates"
+
+// PatchStore keeps the data of every patch in a cache store under these
+// keys:
+//
+//	<patch>/heroes
+//	<patch>/radiant_dire_winrate
+//	<patch>/counters/<hero slug>
+//	<patch>/counters/<filter key>/<hero slug>
+//
+// and the release dates of the patches under meta/patch_dates.
+type PatchStore struct {
+	Cache CacheStore
+}
+
+func NewPatchStore(cache CacheStore) *PatchStore {
+	return &PatchStore{Cache: cache}
+}
+
+// Patches lists the patches that have data in the store, oldest first.
+func (p *PatchStore) Patches() ([]string, error) {
+	keys, err := p.Cache.Keys("")
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+	for _, key := range keys {
+		patch, _, _ := strings.Cut(key, "/")
+		if key == patchDatesKey {
+			continue
+		}
+		if !seen[patch] && validPatch(patch) == nil {
+			seen[patch] = true
+			res = append(res, patch)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (p *PatchStore) key(patch string, elem ...string) (string, error) {
+	err := validPatch(patch)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{patch}
+	for _, e := range elem {
+		if e != "" {
+			parts = append(parts, e)
+		}
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// load decodes the entry into v. With fresh set an entry past its TTL
+// counts as missing, otherwise any age is accepted. Entries that do not
+// decode count as missing too.
+func (p *PatchStore) load(key string, fresh bool, v any) (bool, error) {
+	entry, ok, err := p.Cache.Get(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if fresh && entry.Meta.Expired() {
+		return false, nil
+	}
+	err = json.Unmarshal(entry.Data, v)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Ignoring broken cache entry")
+		return false, nil
+	}
+	return true, nil
+}
+
+func (p *PatchStore) save(key string, sourceURL string, ttl time.Duration, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.Cache.Put(key, &CacheEntry{
+		Meta: CacheMeta{
+			FetchedAt:     time.Now(),
+			SourceURL:     sourceURL,
+			SchemaVersion: CacheSchemaVersion,
+			TTL:           ttl,
+		},
+		Data: b,
+	})
+}
+
+func (p *PatchStore) LoadHeroes(patch string) ([]*Hero, bool, error) {
+	key, err := p.key(patch, "heroes")
+	if err != nil {
+		return nil, false, err
+	}
+	var heroes []*Hero
+	ok, err := p.load(key, true, &heroes)
+	return heroes, ok && len(heroes) > 0, err
+}
+
+func (p *PatchStore) SaveHeroes(patch string, sourceURL string, heroes []*Hero) error {
+	key, err := p.key(patch, "heroes")
+	if err != nil {
+		return err
+	}
+	return p.save(key, sourceURL, HeroesTTL, heroes)
+}
+
+// LoadPatchDates returns the release dates of the patches. With fresh set
+// dates older than PatchDatesTTL count as missing.
+func (p *PatchStore) LoadPatchDates(fresh bool) (map[string]time.Time, bool, error) {
+	var dates map[string]time.Time
+	ok, err := p.load(patchDatesKey, fresh, &dates)
+	return dates, ok && len(dates) > 0, err
+}
+
+func (p *PatchStore) SavePatchDates(sourceURL string, dates map[string]time.Time) error {
+	return p.save(patchDatesKey, sourceURL, PatchDatesTTL, dates)
+}
+
+// PatchStarts returns the release date of every patch, from the patch
+// store while it is fresh, otherwise from the source, and from the stale
+// store when the source fails.
+func (e *Engine) PatchStarts() (map[string]time.Time, error) {
+	dates, ok, err := e.store.LoadPatchDates(true)
+	if err == nil && ok {
+		return dates, nil
+	}
+	if src, ok := e.source.(PatchDater); ok {
+		dates, err = src.PatchDates()
+		if err == nil {
+			err = e.store.SavePatchDates("", dates)
+			if err != nil {
+				log.Error().Err(err).Msg("Error saving patch dates")
+			}
+			return dates, nil
+		}
+		log.Warn().Err(err).Msg("Error fetching patch dates")
+	}
+	dates, ok, err = e.store.LoadPatchDates(false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("Release dates of the patches are unknown")
+	}
+	return dates, nil
+}
+
+// patchAt returns the latest patch that started before t, empty when t is
+// older than all of them.
+func patchAt(starts map[string]time.Time, t time.Time) string {
+	var patch string
+	var start time.Time
+	for name, s := range starts {
+		if !s.After(t) && (patch == "" || s.After(start)) {
+			patch, start = name, s
+		}
+	}
+	return patch
+}
+
+// patchRange returns when the patch started and when the next one did,
+// the end is zero for the latest patch.
+func patchRange(starts map[string]time.Time, patch string) (time.Time, time.Time, bool) {
+	from, ok := starts[patch]
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	var to time.Time
+	for _, s := range starts {
+		if s.After(from) && (to.IsZero() || s.Before(to)) {
+			to = s
+		}
+	}
+	return from, to, true
+}
+
+func (p *PatchStore) LoadSideWR(patch string, fresh bool) ([]*RadiantDireWinrate, bool, error) {
+	key, err := p.key(patch, "radiant_dire_winrate")
+	if err != nil {
+		return nil, false, err
+	}
+	var wrs []*RadiantDireWinrate
+	ok, err := p.load(key, fresh, &wrs)
+	return wrs, ok && len(wrs) > 0, err
+}
+
+func (p *PatchStore) SaveSideWR(patch string, sourceURL string, wrs []*RadiantDireWinrate) error {
+	key, err := p.key(patch, "radiant_dire_winrate")
+	if err != nil {
+		return err
+	}
+	return p.save(key, sourceURL, SideWRTTL, wrs)
+}
+
+func (p *PatchStore) LoadCounters(patch string, filter CounterFilter, hero *Hero, fresh bool) ([]*Counter, bool, error) {
+	key, err := p.key(patch, "counters", filter.Key(), hero.Slug)
+	if err != nil {
+		return nil, false, err
+	}
+	var counters []*Counter
+	ok, err := p.load(key, fresh, &counters)
+	return counters, ok && len(counters) > 0, err
+}
+
+func (p *PatchStore) SaveCounters(patch string, filter CounterFilter, hero *Hero, sourceURL string, counters []*Counter) error {
+	key, err := p.key(patch, "counters", filter.Key(), hero.Slug)
+	if err != nil {
+		return err
+	}
+	return p.save(key, sourceURL, CountersTTL, counters)
+}
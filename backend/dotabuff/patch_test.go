@@ -0,0 +1,29 @@
+package dotabuff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPatchRange(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	starts := map[string]time.Time{"7.35": day(1), "7.36": day(10), "7.37": day(20)}
+	from, to, ok := patchRange(starts, "7.36")
+	if !ok || !from.Equal(day(10)) || !to.Equal(day(20)) {
+		t.Fatalf("got %v %v %v", from, to, ok)
+	}
+	from, to, ok = patchRange(starts, "7.37")
+	if !ok || !from.Equal(day(20)) || !to.IsZero() {
+		t.Fatalf("got %v %v %v for the latest patch", from, to, ok)
+	}
+	_, _, ok = patchRange(starts, "7.34")
+	if ok {
+		t.Fatal("unknown patch has a range")
+	}
+	if patch := patchAt(starts, day(15)); patch != "7.36" {
+		t.Fatalf("got %q, want 7.36", patch)
+	}
+	if patch := patchAt(starts, day(20)); patch != "7.37" {
+		t.Fatalf("got %q, want 7.37 on its release day", patch)
+	}
+}
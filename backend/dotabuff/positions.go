@@ -0,0 +1,117 @@
+package dotabuff
+
+import (
+	"sort"
+	"strings"
+)
+
+// Lanes a player can be assigned to in the laning stage.
+const (
+	LaneSafe    = "safe"
+	LaneMid     = "mid"
+	LaneOff     = "off"
+	LaneJungle  = "jungle"
+	LaneRoaming = "roaming"
+)
+
+// lanePositions lists the positions a player of the lane may play, the
+// richer player of a lane takes the first free one.
+var lanePositions = map[string][]int{
+	LaneSafe:    {1, 5},
+	LaneMid:     {2},
+	LaneOff:     {3, 4},
+	LaneJungle:  {4, 5, 3},
+	LaneRoaming: {4, 5},
+}
+
+// ParseLane maps the lane labels of dotabuff ("Safe Lane", "Off Lane",
+// "Roaming" ...) to a lane, unknown labels are empty.
+func ParseLane(s string) string {
+	s = strings.ToLower(s)
+	switch {
+	case strings.Contains(s, "safe"):
+		return LaneSafe
+	case strings.Contains(s, "mid"):
+		return LaneMid
+	case strings.Contains(s, "off"):
+		return LaneOff
+	case strings.Contains(s, "jungle"):
+		return LaneJungle
+	case strings.Contains(s, "roam"):
+		return LaneRoaming
+	}
+	return ""
+}
+
+// AssignPositions sets the position 1-5 of every player of both sides.
+// Players are taken by net worth, richest first, and get the first free
+// position of their lane. Players without a lane, or whose lane has no
+// free position left, get the remaining positions in the same order, so
+// a match without lane data falls back to the net worth ranking.
+func AssignPositions(players []*MatchPlayer) {
+	for _, radiant := range []bool{true, false} {
+		side := make([]*MatchPlayer, 0, 5)
+		for _, p := range players {
+			if p.Radiant == radiant {
+				side = append(side, p)
+			}
+		}
+		assignSidePositions(side)
+	}
+}
+
+func assignSidePositions(side []*MatchPlayer) {
+	sort.SliceStable(side, func(i, j int) bool { return side[i].NetWorth > side[j].NetWorth })
+	taken := make(map[int]bool, len(side))
+	for _, p := range side {
+		p.Position = 0
+		for _, pos := range lanePositions[p.Lane] {
+			if !taken[pos] {
+				p.Position = pos
+				taken[pos] = true
+				break
+			}
+		}
+	}
+	next := 1
+	for _, p := range side {
+		if p.Position != 0 {
+			continue
+		}
+		for taken[next] {
+			next++
+		}
+		p.Position = next
+		taken[next] = true
+	}
+}
+
+// PlayersByPosition returns the players of a side ordered from position 1
+// to 5.
+func (m *DotabuffMatch) PlayersByPosition(radiant bool) []*MatchPlayer {
+	res := make([]*MatchPlayer, 0, 5)
+	for _, p := range m.Players {
+		if p.Radiant == radiant {
+			res = append(res, p)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Position < res[j].Position })
+	return res
+}
+
+// HeroesByPosition returns the heroes of a side ordered by position, or
+// in table order when the match has no complete player data.
+func (m *DotabuffMatch) HeroesByPosition(radiant bool) []*Hero {
+	players := m.PlayersByPosition(radiant)
+	if len(players) != 5 {
+		if radiant {
+			return m.Radiant
+		}
+		return m.Dire
+	}
+	heroes := make([]*Hero, 0, 5)
+	for _, p := range players {
+		heroes = append(heroes, p.Hero)
+	}
+	return heroes
+}
@@ -0,0 +1,165 @@
+package dotabuff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Algorithm versions stored with every prediction.
+const (
+	// AlgorithmAverage averages the raw matchup winrates of every hero and
+	// normalizes the result, v1.1 stored the raw scores
+	AlgorithmAverage = "v1.2"
+	// AlgorithmLogOdds sums the matchup advantages as log-odds, see
+	// LogOddsModel
+	AlgorithmLogOdds = "v2.0"
+	DefaultAlgorithm = AlgorithmAverage
+)
+
+// Predictor scores a draft with the counters of a dataset, the radiant and
+// dire win probabilities are in percent and sum to 100. The draft is
+// complete but for the draft timeline, which scores every pick with at
+// least one hero on each side.
+type Predictor interface {
+	// Version is stored with every prediction of the predictor, it has to
+	// change whenever the predictions do
+	Version() string
+	WinRate(d *Dataset, radiant, dire []*Hero) (float64, float64)
+}
+
+// AveragePredictor is the original model, see Dataset.PickWinRate.
+type AveragePredictor struct{}
+
+func (AveragePredictor) Version() string {
+	return AlgorithmAverage
+}
+
+func (AveragePredictor) WinRate(d *Dataset, radiant, dire []*Hero) (float64, float64) {
+	return d.PickWinRate(radiant, dire)
+}
+
+func (AveragePredictor) RawWinRate(d *Dataset, radiant, dire []*Hero) (float64, float64) {
+	return d.RawPickWinRate(radiant, dire)
+}
+
+// RawScorer is implemented by the predictors whose probabilities come
+// from other scores, they are kept with the predictions for diagnostics.
+type RawScorer interface {
+	RawWinRate(d *Dataset, radiant, dire []*Hero) (float64, float64)
+}
+
+// Score scores the draft with the predictor and the dataset. The raw
+// scores equal the probabilities for predictors without a RawScorer.
+func Score(p Predictor, ds *Dataset, radiant, dire []*Hero) *Prediction {
+	rw, dw := p.WinRate(ds, radiant, dire)
+	prediction := &Prediction{
+		AlgorithmVersion: p.Version(),
+		Patch:            ds.Patch,
+		CounterFilter:    ds.Key(),
+		RadiantWin:       rw,
+		DireWin:          dw,
+		RawRadiantWin:    rw,
+		RawDireWin:       dw,
+	}
+	if raw, ok := p.(RawScorer); ok {
+		prediction.RawRadiantWin, prediction.RawDireWin = raw.RawWinRate(ds, radiant, dire)
+	}
+	return prediction
+}
+
+var (
+	predictorsLock sync.RWMutex
+	predictors     = make(map[string]Predictor)
+)
+
+func init() {
+	RegisterPredictor(AveragePredictor{})
+	RegisterPredictor(DefaultLogOddsModel)
+}
+
+// RegisterPredictor makes the predictor selectable by its version and
+// adds it to the shadow predictions. It panics when the version is taken.
+func RegisterPredictor(p Predictor) {
+	predictorsLock.Lock()
+	defer predictorsLock.Unlock()
+	if _, ok := predictors[p.Version()]; ok {
+		panic(fmt.Sprintf("Predictor %s is already registered", p.Version()))
+	}
+	predictors[p.Version()] = p
+}
+
+// PredictorVersions lists the registered versions in order.
+func PredictorVersions() []string {
+	predictorsLock.RLock()
+	defer predictorsLock.RUnlock()
+	versions := make([]string, 0, len(predictors))
+	for v := range predictors {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// PredictorFor returns the predictor of the version, DefaultAlgorithm when
+// the version is empty.
+func PredictorFor(version string) (Predictor, error) {
+	if version == "" {
+		version = DefaultAlgorithm
+	}
+	predictorsLock.RLock()
+	p, ok := predictors[version]
+	predictorsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown algorithm %q, use one of: %s", version, strings.Join(PredictorVersions(), ", "))
+	}
+	return p, nil
+}
+
+// predict scores the match with the predictor and the dataset of opts.
+func (e *Engine) predict(p Predictor, match *DotabuffMatch, opts PickOptions) (*Prediction, error) {
+	ds, err := e.DatasetFor(opts)
+	if err != nil {
+		return nil, err
+	}
+	err = ds.CheckCounters(match.Radiant, match.Dire)
+	if err != nil {
+		return nil, err
+	}
+	return Score(p, ds, match.Radiant, match.Dire), nil
+}
+
+// ShadowPredictions returns the prediction of the match by every
+// registered predictor other than the one of primary, with the same data.
+// A predictor that fails is logged and left out.
+func (e *Engine) ShadowPredictions(match *DotabuffMatch, opts PickOptions, primary *Prediction) []*Prediction {
+	res := make([]*Prediction, 0)
+	for _, version := range PredictorVersions() {
+		if version == primary.AlgorithmVersion {
+			continue
+		}
+		p, err := PredictorFor(version)
+		if err != nil {
+			continue
+		}
+		prediction, err := e.predict(p, match, opts)
+		if err != nil {
+			log.Warn().Err(err).Str("algorithm", version).Int64("match", match.MatchID).Msg("Error making shadow prediction")
+			continue
+		}
+		res = append(res, prediction)
+	}
+	return res
+}
+
+// storedPredictions is what gets stored for the match: the prediction
+// itself and, in shadow mode, the ones of the other predictors.
+func (e *Engine) storedPredictions(match *DotabuffMatch, opts PickOptions, p *Prediction) []*Prediction {
+	if !e.Shadow {
+		return []*Prediction{p}
+	}
+	return append([]*Prediction{p}, e.ShadowPredictions(match, opts, p)...)
+}
@@ -0,0 +1,158 @@
+package dotabuff
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CountersPro selects the counter table computed from the pro matches
+// stored in MySQL instead of the dotabuff counters.
+const CountersPro = "pro"
+
+// proPriorGames is the weight of the 50% prior in the matchup winrates,
+// it keeps a hero pair seen in two games from looking like a hard counter.
+const proPriorGames = 10
+
+// proDatasetTTL is how long an aggregated pro counter table is reused.
+const proDatasetTTL = 30 * time.Minute
+
+// ProMatch is the draft and the result of a stored match.
+type ProMatch struct {
+	MatchID    int64
+	Radiant    []int
+	Dire       []int
+	RadiantWon bool
+}
+
+// MatchupStats counts games and wins of every hero against every other
+// hero, Games[a][b] is the number of games a played against b and
+// Wins[a][b] how many of them a won.
+type MatchupStats struct {
+	Matches int
+	Games   map[int]map[int]int64
+	Wins    map[int]map[int]int64
+	// HeroGames and HeroWins are the totals of every hero
+	HeroGames map[int]int64
+	HeroWins  map[int]int64
+}
+
+func AggregateMatchups(matches []*ProMatch) *MatchupStats {
+	s := &MatchupStats{
+		Games:     make(map[int]map[int]int64),
+		Wins:      make(map[int]map[int]int64),
+		HeroGames: make(map[int]int64),
+		HeroWins:  make(map[int]int64),
+	}
+	for _, m := range matches {
+		if len(m.Radiant) != 5 || len(m.Dire) != 5 {
+			continue
+		}
+		s.Matches++
+		s.addSide(m.Radiant, m.Dire, m.RadiantWon)
+		s.addSide(m.Dire, m.Radiant, !m.RadiantWon)
+	}
+	return s
+}
+
+func (s *MatchupStats) addSide(heroes, enemies []int, won bool) {
+	for _, h := range heroes {
+		s.HeroGames[h]++
+		if won {
+			s.HeroWins[h]++
+		}
+		if s.Games[h] == nil {
+			s.Games[h] = make(map[int]int64)
+			s.Wins[h] = make(map[int]int64)
+		}
+		for _, e := range enemies {
+			s.Games[h][e]++
+			if won {
+				s.Wins[h][e]++
+			}
+		}
+	}
+}
+
+func smoothedWinRate(wins, games int64) float64 {
+	return (float64(wins) + proPriorGames*0.5) / (float64(games) + proPriorGames) * 100
+}
+
+// Counters builds dotabuff style counters for every pair of heroes: the
+// counters of hero X hold the winrate of every opponent Y against X, with
+// the sample size in MatchesPlayed. Pairs that never met get 50%.
+func (s *MatchupStats) Counters(heroes []*Hero) map[int][]*Counter {
+	res := make(map[int][]*Counter, len(heroes))
+	for _, x := range heroes {
+		counters := make([]*Counter, 0, len(heroes)-1)
+		for _, y := range heroes {
+			if x.ID == y.ID {
+				continue
+			}
+			games := s.Games[y.ID][x.ID]
+			winRate := smoothedWinRate(s.Wins[y.ID][x.ID], games)
+			base := smoothedWinRate(s.HeroWins[y.ID], s.HeroGames[y.ID])
+			counters = append(counters, &Counter{
+				Hero:          y,
+				Disadvantage:  winRate - base,
+				WinRate:       winRate,
+				MatchesPlayed: games,
+			})
+		}
+		res[x.ID] = counters
+	}
+	return res
+}
+
+type proDataset struct {
+	ds      *Dataset
+	builtAt time.Time
+}
+
+// ProDataset returns the counter table aggregated from the stored pro
+// matches of the patch, or of every patch when patch is empty.
+func (e *Engine) ProDataset(patch string) (*Dataset, error) {
+	if e.mysql == nil {
+		return nil, fmt.Errorf("Pro match counters need MySQL")
+	}
+	e.lock.Lock()
+	cached, ok := e.proDatasets[patch]
+	sideWR := e.SideWR
+	e.lock.Unlock()
+	if ok && time.Since(cached.builtAt) < proDatasetTTL {
+		return cached.ds, nil
+	}
+	// the patch column is the one the match was scored with, the patch it
+	// was played on comes from its start time
+	var from, to time.Time
+	if patch != "" {
+		starts, err := e.PatchStarts()
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		from, to, ok = patchRange(starts, patch)
+		if !ok {
+			return nil, fmt.Errorf("Release date of patch %s is unknown", patch)
+		}
+	}
+	matches, err := e.mysql.ProMatches(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("No pro matches stored for patch %q", patch)
+	}
+	stats := AggregateMatchups(matches)
+	ds := NewDataset(patch)
+	ds.Table = CountersPro
+	ds.Heroes = RegistryHeroes()
+	ds.SetSideWR(sideWR)
+	ds.SetCounters(stats.Counters(ds.Heroes))
+	log.Info().Str("patch", patch).Int("matches", stats.Matches).Msg("Pro match counters has been aggregated")
+	e.lock.Lock()
+	e.proDatasets[patch] = &proDataset{ds: ds, builtAt: time.Now()}
+	e.lock.Unlock()
+	return ds, nil
+}
@@ -0,0 +1,87 @@
+package dotabuff
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket refilled with rps tokens per second and
+// holding at most burst tokens.
+type RateLimiter struct {
+	lock   sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rps:    rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available. Callers that have to wait
+// reserve their token upfront, so concurrent callers queue up in order.
+func (l *RateLimiter) Wait() {
+	if l == nil || l.rps <= 0 {
+		return
+	}
+	l.lock.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rps
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rps * float64(time.Second))
+	}
+	l.lock.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// HostRateLimiter keeps a separate token bucket for every host.
+type HostRateLimiter struct {
+	lock     sync.Mutex
+	rps      float64
+	burst    int
+	limiters map[string]*RateLimiter
+}
+
+func NewHostRateLimiter(rps float64, burst int) *HostRateLimiter {
+	return &HostRateLimiter{
+		rps:      rps,
+		burst:    burst,
+		limiters: make(map[string]*RateLimiter),
+	}
+}
+
+func (h *HostRateLimiter) Wait(rawURL string) {
+	if h == nil || h.rps <= 0 {
+		return
+	}
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	h.lock.Lock()
+	limiter, ok := h.limiters[host]
+	if !ok {
+		limiter = NewRateLimiter(h.rps, h.burst)
+		h.limiters[host] = limiter
+	}
+	h.lock.Unlock()
+	limiter.Wait()
+}
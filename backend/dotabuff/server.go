@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/rs/cors"
 	"github.com/rs/zerolog/log"
 )
@@ -17,17 +20,62 @@ type Server struct {
 }
 
 type Status struct {
-	Ready bool `json:"ready"`
+	Ready bool   `json:"ready"`
+	Patch string `json:"patch"`
+	// SideSplit is false when the data source has no radiant and dire
+	// winrates, e.g. OpenDota
+	SideSplit bool `json:"side_split"`
 }
 
 type PickWinrateRequest struct {
 	Radiant []string `json:"radiant"`
 	Dire    []string `json:"dire"`
+	// Patch is optional, the current patch is used when empty
+	Patch string `json:"patch"`
+	// Window and Bracket are optional, see CounterFilter
+	Window  string `json:"window"`
+	Bracket string `json:"bracket"`
+	// Counters is optional, "pro" scores with the stored pro matches
+	Counters string `json:"counters"`
+	// Algorithm is optional, one of the registered predictor versions
+	Algorithm string `json:"algorithm"`
 }
 
 type PickWinrateResponse struct {
 	RadiantWinrate float64 `json:"radiant_winrate"`
 	DireWinrate    float64 `json:"dire_winrate"`
+	Patch          string  `json:"patch"`
+	CounterFilter  string  `json:"counter_filter"`
+	Algorithm      string  `json:"algorithm"`
+	// the scores the winrates were normalized from, for diagnostics
+	RawRadiantWinrate float64 `json:"raw_radiant_winrate"`
+	RawDireWinrate    float64 `json:"raw_dire_winrate"`
+}
+
+type AlgorithmsResponse struct {
+	Default    string   `json:"default"`
+	Algorithms []string `json:"algorithms"`
+}
+
+type DraftStepResponse struct {
+	Order          int     `json:"order"`
+	Pick           bool    `json:"pick"`
+	Radiant        bool    `json:"radiant"`
+	Hero           string  `json:"hero"`
+	RadiantWinrate float64 `json:"radiant_winrate"`
+	DireWinrate    float64 `json:"dire_winrate"`
+}
+
+type TeamHeroResponse struct {
+	Hero    string  `json:"hero"`
+	Matches int     `json:"matches"`
+	Winrate float64 `json:"winrate"`
+}
+
+type TeamHeroPoolResponse struct {
+	Team   string             `json:"team"`
+	Roster []string           `json:"roster"`
+	Heroes []TeamHeroResponse `json:"heroes"`
 }
 
 func NewServer(engine *Engine, tg *TelegramBot) *Server {
@@ -66,7 +114,32 @@ func (s *Server) Start(port int) error {
 			http.Error(w, "line is invalid", http.StatusBadRequest)
 			return
 		}
-		s.Tg.SendPickWinRatesToUser(chatId, 0, lines)
+		if s.Tg == nil {
+			http.Error(w, "telegram bot is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		opts := PickOptions{
+			Patch: r.URL.Query().Get("patch"),
+			Filter: CounterFilter{
+				Window:  r.URL.Query().Get("window"),
+				Bracket: r.URL.Query().Get("bracket"),
+			},
+			Counters:  r.URL.Query().Get("counters"),
+			Algorithm: r.URL.Query().Get("algorithm"),
+		}
+		err = opts.Filter.Validate()
+		if err == nil {
+			_, err = PredictorFor(opts.Algorithm)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = s.Tg.SendPickWinRatesToUser(chatId, 0, lines, opts)
+		if IsLayoutChanged(err) {
+			http.Error(w, "dotabuff layout changed", http.StatusBadGateway)
+			return
+		}
 		w.Write([]byte(`{"message": "Sending pick winrates to user"}`))
 	})
 	// LGD vs IG
@@ -98,10 +171,41 @@ func (s *Server) Start(port int) error {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		radiantWinrate, direWinrate := s.Engine.PickWinRate(radiantHeroes, direHeroes)
+		filter := CounterFilter{Window: req.Window, Bracket: req.Bracket}
+		err = filter.Validate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		predictor, err := PredictorFor(req.Algorithm)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ds, err := s.Engine.DatasetFor(PickOptions{Patch: req.Patch, Filter: filter, Counters: req.Counters})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		err = ds.CheckCounters(radiantHeroes, direHeroes)
+		if IsLayoutChanged(err) {
+			http.Error(w, "dotabuff layout changed", http.StatusBadGateway)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Error checking counters")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p := Score(predictor, ds, radiantHeroes, direHeroes)
 		resp := PickWinrateResponse{
-			RadiantWinrate: radiantWinrate,
-			DireWinrate:    direWinrate,
+			RadiantWinrate:    p.RadiantWin,
+			DireWinrate:       p.DireWin,
+			Patch:             p.Patch,
+			CounterFilter:     p.CounterFilter,
+			Algorithm:         p.AlgorithmVersion,
+			RawRadiantWinrate: p.RawRadiantWin,
+			RawDireWinrate:    p.RawDireWin,
 		}
 		json, err := json.Marshal(resp)
 		if err != nil {
@@ -112,10 +216,169 @@ func (s *Server) Start(port int) error {
 		w.Write(json)
 	})
 
+	// curl http://localhost:8080/algorithms_v1
+	mux.HandleFunc("/algorithms_v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		resp := AlgorithmsResponse{
+			Default:    DefaultAlgorithm,
+			Algorithms: PredictorVersions(),
+		}
+		json, err := json.Marshal(resp)
+		if err != nil {
+			log.Error().Err(err).Msg("Error marshalling algorithms")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(json)
+	})
+
+	// curl http://localhost:8080/calibration_v1?algorithm=v1.2&format=svg
+	mux.HandleFunc("/calibration_v1", func(w http.ResponseWriter, r *http.Request) {
+		report, err := s.Engine.CalibrationReport()
+		if err != nil {
+			log.Error().Err(err).Msg("Error building calibration report")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if algorithm := r.URL.Query().Get("algorithm"); algorithm != "" {
+			report = report.Algorithm(algorithm)
+		}
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "", "json":
+		case "png", "svg":
+			path := filepath.Join(os.TempDir(), uuid.New().String()+"."+format)
+			defer os.Remove(path)
+			err = report.ReliabilityDiagram(path)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if format == "svg" {
+				w.Header().Set("Content-Type", "image/svg+xml")
+			} else {
+				w.Header().Set("Content-Type", "image/png")
+			}
+			http.ServeFile(w, r, path)
+			return
+		default:
+			http.Error(w, "format must be json, png or svg", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json, err := json.Marshal(report)
+		if err != nil {
+			log.Error().Err(err).Msg("Error marshalling calibration report")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(json)
+	})
+
+	// curl http://localhost:8080/draft-winrate_v1?match=7000000000&algorithm=v2.0
+	mux.HandleFunc("/draft-winrate_v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		matchId, err := strconv.ParseInt(r.URL.Query().Get("match"), 10, 64)
+		if err != nil {
+			http.Error(w, "match is invalid", http.StatusBadRequest)
+			return
+		}
+		opts := PickOptions{
+			Patch: r.URL.Query().Get("patch"),
+			Filter: CounterFilter{
+				Window:  r.URL.Query().Get("window"),
+				Bracket: r.URL.Query().Get("bracket"),
+			},
+			Counters:  r.URL.Query().Get("counters"),
+			Algorithm: r.URL.Query().Get("algorithm"),
+		}
+		_, err = PredictorFor(opts.Algorithm)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		match, err := s.Engine.Match(matchId)
+		if IsLayoutChanged(err) {
+			http.Error(w, "dotabuff layout changed", http.StatusBadGateway)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Error fetching match")
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		steps, err := s.Engine.DraftWinRates(match, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		resp := make([]DraftStepResponse, 0, len(steps))
+		for _, step := range steps {
+			resp = append(resp, DraftStepResponse{
+				Order:          step.Action.Order,
+				Pick:           step.Action.Pick,
+				Radiant:        step.Action.Radiant,
+				Hero:           step.Action.Hero.Name,
+				RadiantWinrate: step.RadiantWin,
+				DireWinrate:    step.DireWin,
+			})
+		}
+		json, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(json)
+	})
+
+	// curl http://localhost:8080/team-heroes_v1?team=2163&limit=20
+	mux.HandleFunc("/team-heroes_v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		limit := 0
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.Atoi(l)
+			if err != nil {
+				http.Error(w, "limit is invalid", http.StatusBadRequest)
+				return
+			}
+		}
+		team, err := s.Engine.TeamProfile(r.URL.Query().Get("team"))
+		if IsLayoutChanged(err) {
+			http.Error(w, "dotabuff layout changed", http.StatusBadGateway)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pool, _ := s.Engine.TeamHeroPool(team.Link, limit)
+		resp := TeamHeroPoolResponse{
+			Team:   team.Name,
+			Roster: make([]string, 0, len(team.Roster)),
+			Heroes: make([]TeamHeroResponse, 0, len(pool)),
+		}
+		for _, p := range team.Roster {
+			resp.Roster = append(resp.Roster, p.Name)
+		}
+		for _, h := range pool {
+			resp.Heroes = append(resp.Heroes, TeamHeroResponse{Hero: h.Hero.Name, Matches: h.Matches, Winrate: h.WinRate})
+		}
+		json, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(json)
+	})
+
 	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		ds, _ := s.Engine.DatasetForPatch("")
 		status := Status{
-			Ready: s.Engine.Loaded(),
+			Ready:     s.Engine.Loaded(),
+			Patch:     ds.Patch,
+			SideSplit: ds.HasSideSplit(),
 		}
 		json, err := json.Marshal(status)
 		if err != nil {
@@ -0,0 +1,333 @@
+package dotabuff
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SnapshotVersion is the format version of the snapshot archives, an
+// archive of another version is refused.
+const SnapshotVersion = 1
+
+// SnapshotManifest is the manifest.json of a snapshot archive. The archive
+// is a tar.gz holding:
+//
+//	manifest.json
+//	heroes.json
+//	radiant_dire_winrate.json
+//	counters/default.json
+//	counters/<filter key>.json
+//	cache.json
+//
+// cache.json holds the cache entries of the patch by key, along with their
+// metadata, archives without it are still imported.
+type SnapshotManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Patch     string    `json:"patch"`
+	Heroes    int       `json:"heroes"`
+	// Filters are the keys of the counter filters in the archive, the
+	// default counters are stored as "default"
+	Filters []string `json:"filters"`
+}
+
+const (
+	snapshotDefaultFilter = "default"
+	snapshotCacheFile     = "cache.json"
+)
+
+// ExportSnapshot writes the current dataset, including the counters of
+// every loaded filter, and the cache entries of its patch to a snapshot
+// archive.
+func (e *Engine) ExportSnapshot(path string) (*SnapshotManifest, error) {
+	if !e.Loaded() {
+		return nil, fmt.Errorf("Data has not been loaded yet")
+	}
+	e.lock.Lock()
+	ds := e.Dataset
+	files := map[string]any{
+		"heroes.json":                                 ds.Heroes,
+		"radiant_dire_winrate.json":                   ds.SideWR,
+		"counters/" + snapshotDefaultFilter + ".json": ds.Counters,
+	}
+	manifest := &SnapshotManifest{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now().UTC(),
+		Patch:     ds.Patch,
+		Heroes:    len(ds.Heroes),
+		Filters:   []string{snapshotDefaultFilter},
+	}
+	for key, f := range ds.filtered {
+		if f.countersLoaded {
+			files["counters/"+key+".json"] = f.Counters
+			manifest.Filters = append(manifest.Filters, key)
+		}
+	}
+	// encode while holding the lock, the counters are replaced on reload
+	encoded := make(map[string][]byte, len(files)+2)
+	for name, v := range files {
+		b, err := json.Marshal(v)
+		if err != nil {
+			e.lock.Unlock()
+			return nil, err
+		}
+		encoded[name] = b
+	}
+	store := e.store
+	e.lock.Unlock()
+	entries, err := patchCacheEntries(store.Cache, manifest.Patch)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	encoded[snapshotCacheFile] = b
+	sort.Strings(manifest.Filters[1:])
+	b, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	encoded["manifest.json"] = b
+
+	err = writeSnapshot(path, encoded)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("path", path).Str("patch", manifest.Patch).Strs("filters", manifest.Filters).Msg("Snapshot has been exported")
+	return manifest, nil
+}
+
+func writeSnapshot(path string, files map[string][]byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	// the manifest goes first so that readers can check the version early
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "manifest.json" || names[j] == "manifest.json" {
+			return names[i] == "manifest.json"
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), ModTime: time.Now()})
+		if err == nil {
+			_, err = tw.Write(files[name])
+		}
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	err = tw.Close()
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readSnapshot(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid snapshot %s: %v", path, err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid snapshot %s: %v", path, err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid snapshot %s: %v", path, err)
+		}
+		files[h.Name] = b
+	}
+	return files, nil
+}
+
+// ImportSnapshot replaces the current dataset with the one of a snapshot
+// archive. Nothing is fetched, the engine is ready once it returns.
+func (e *Engine) ImportSnapshot(path string) (*SnapshotManifest, error) {
+	files, err := readSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &SnapshotManifest{}
+	err = decodeSnapshotFile(files, "manifest.json", manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Version != SnapshotVersion {
+		return nil, fmt.Errorf("Unsupported snapshot version %d, expected %d", manifest.Version, SnapshotVersion)
+	}
+	ds := NewDataset(manifest.Patch)
+	var heroes []*Hero
+	err = decodeSnapshotFile(files, "heroes.json", &heroes)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range heroes {
+		if hero := CanonicalHero(h); hero.ID != 0 {
+			ds.Heroes = append(ds.Heroes, hero)
+		}
+	}
+	if len(ds.Heroes) == 0 {
+		return nil, fmt.Errorf("Snapshot %s has no heroes", path)
+	}
+	var wrs []*RadiantDireWinrate
+	err = decodeSnapshotFile(files, "radiant_dire_winrate.json", &wrs)
+	if err != nil {
+		return nil, err
+	}
+	ds.SetSideWR(wrs)
+	for _, key := range manifest.Filters {
+		counters := make(map[int][]*Counter)
+		err = decodeSnapshotFile(files, "counters/"+key+".json", &counters)
+		if err != nil {
+			return nil, err
+		}
+		if key == snapshotDefaultFilter {
+			ds.SetCounters(counters)
+			continue
+		}
+		filter, err := filterFromKey(key)
+		if err != nil {
+			return nil, err
+		}
+		f := ds.withFilter(filter)
+		f.SetCounters(counters)
+		ds.filtered[key] = f
+	}
+	if !ds.countersLoaded {
+		return nil, fmt.Errorf("Snapshot %s has no default counters", path)
+	}
+	var entries map[string]*CacheEntry
+	if _, ok := files[snapshotCacheFile]; ok {
+		err = decodeSnapshotFile(files, snapshotCacheFile, &entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+	e.lock.Lock()
+	e.setDataset(ds)
+	e.addHeroNames(ds.Heroes)
+	store := e.store
+	e.lock.Unlock()
+	restoreCacheEntries(store.Cache, manifest.Patch, entries)
+	log.Info().Str("path", path).Str("patch", manifest.Patch).Time("created", manifest.CreatedAt).Msg("Snapshot has been imported")
+	return manifest, nil
+}
+
+// patchCacheEntries returns the entries of the cache under the patch.
+func patchCacheEntries(cache CacheStore, patch string) (map[string]*CacheEntry, error) {
+	keys, err := cache.Keys(patch + "/")
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]*CacheEntry, len(keys))
+	for _, key := range keys {
+		entry, ok, err := cache.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			entries[key] = entry
+		}
+	}
+	return entries, nil
+}
+
+// restoreCacheEntries puts the entries of a snapshot in the cache with
+// their metadata, an entry the cache already has a newer copy of is
+// skipped. Entries outside the patch or of another schema are ignored.
+func restoreCacheEntries(cache CacheStore, patch string, entries map[string]*CacheEntry) {
+	restored := 0
+	for key, entry := range entries {
+		if !strings.HasPrefix(key, patch+"/") || validCacheKey(key) != nil || entry == nil || entry.Meta.SchemaVersion != CacheSchemaVersion {
+			log.Warn().Str("key", key).Msg("Ignoring snapshot cache entry")
+			continue
+		}
+		current, ok, err := cache.Get(key)
+		if err == nil && ok && !current.Meta.FetchedAt.Before(entry.Meta.FetchedAt) {
+			continue
+		}
+		err = cache.Put(key, entry)
+		if err != nil {
+			log.Error().Err(err).Str("key", key).Msg("Error restoring snapshot cache entry")
+			continue
+		}
+		restored++
+	}
+	log.Info().Str("patch", patch).Int("restored", restored).Int("entries", len(entries)).Msg("Snapshot cache entries has been restored")
+}
+
+func decodeSnapshotFile(files map[string][]byte, name string, v any) error {
+	b, ok := files[name]
+	if !ok {
+		return fmt.Errorf("Snapshot has no %s", name)
+	}
+	err := json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("Error decoding %s of the snapshot: %v", name, err)
+	}
+	return nil
+}
+
+// filterFromKey is the inverse of CounterFilter.Key.
+func filterFromKey(key string) (CounterFilter, error) {
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] != '-' {
+			continue
+		}
+		f := CounterFilter{Window: key[:i], Bracket: key[i+1:]}
+		if f.Window == "all" {
+			f.Window = ""
+		}
+		if f.Bracket == "all" {
+			f.Bracket = ""
+		}
+		if f.Validate() == nil && f.Key() == key {
+			return f, nil
+		}
+	}
+	return CounterFilter{}, fmt.Errorf("Invalid counter filter key: %q", key)
+}
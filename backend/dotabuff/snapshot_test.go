@@ -0,0 +1,105 @@
+package dotabuff
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	heroes := RegistryHeroes()[:10]
+	e := newFixtureEngine(t, NewFixtureSource(heroes, nil, fixtureCounters(heroes)))
+	err := e.LoadHeroes()
+	if err == nil {
+		err = e.LoadCounters()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an entry with a source URL and an old fetch date
+	fetchedAt := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	err = e.store.Cache.Put("fixture/heroes", &CacheEntry{
+		Meta: CacheMeta{FetchedAt: fetchedAt, SourceURL: "https://example.com/heroes", SchemaVersion: CacheSchemaVersion},
+		Data: []byte(`[{"ID": 1, "Name": "Anti-Mage"}]`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := patchCacheEntries(e.store.Cache, "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	_, err = e.ExportSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := newFixtureEngine(t, NewFixtureSource(nil, nil, nil))
+	manifest, err := imported.ImportSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Patch != "fixture" || manifest.Heroes != len(heroes) || !imported.Loaded() {
+		t.Fatalf("got manifest %+v", manifest)
+	}
+	rw, _, err := imported.PickWinRateWithOptions(PickOptions{}, heroes[:5], heroes[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approxEqual(rw, 45) {
+		t.Fatalf("got %v, want 45", rw)
+	}
+	patches, err := imported.store.Patches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(patches, []string{"fixture"}) {
+		t.Fatalf("got patches %v", patches)
+	}
+	got, err := patchCacheEntries(imported.store.Cache, "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) || len(got) != 12 {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for key, w := range want {
+		g, ok := got[key]
+		if !ok {
+			t.Fatalf("%s is missing", key)
+		}
+		if !g.Meta.FetchedAt.Equal(w.Meta.FetchedAt) || g.Meta.SourceURL != w.Meta.SourceURL || g.Meta.TTL != w.Meta.TTL || g.Meta.SchemaVersion != w.Meta.SchemaVersion {
+			t.Fatalf("%s: got meta %+v, want %+v", key, g.Meta, w.Meta)
+		}
+	}
+	if meta := got["fixture/heroes"].Meta; !meta.FetchedAt.Equal(fetchedAt) || meta.SourceURL != "https://example.com/heroes" {
+		t.Fatalf("got heroes meta %+v", meta)
+	}
+}
+
+func TestSnapshotKeepsNewerCacheEntries(t *testing.T) {
+	cache := NewMemoryCache()
+	newer := &CacheEntry{Meta: CacheMeta{FetchedAt: time.Now(), SchemaVersion: CacheSchemaVersion}, Data: []byte(`"newer"`)}
+	older := &CacheEntry{Meta: CacheMeta{FetchedAt: time.Now().Add(-time.Hour), SchemaVersion: CacheSchemaVersion}, Data: []byte(`"older"`)}
+	err := cache.Put("fixture/heroes", newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreCacheEntries(cache, "fixture", map[string]*CacheEntry{
+		"fixture/heroes":     older,
+		"fixture/counters/a": older,
+		"other/heroes":       older,
+	})
+	entry, _, _ := cache.Get("fixture/heroes")
+	if string(entry.Data) != `"newer"` {
+		t.Fatalf("got %s, want the newer entry", entry.Data)
+	}
+	if _, ok, _ := cache.Get("fixture/counters/a"); !ok {
+		t.Fatal("missing entry has not been restored")
+	}
+	if _, ok, _ := cache.Get("other/heroes"); ok {
+		t.Fatal("entry of another patch has been restored")
+	}
+}
@@ -0,0 +1,247 @@
+package dotabuff
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+// teamRecentResults is the number of recent matches kept per team.
+const teamRecentResults = 20
+
+// teamProfileTTL is how long the engine reuses a fetched team profile.
+const teamProfileTTL = 6 * time.Hour
+
+// TeamProfile is what we know about a pro team before its next game.
+type TeamProfile struct {
+	ID        int64
+	Name      string
+	Link      string
+	Roster    []*TeamPlayer
+	Results   []*TeamResult
+	HeroPool  []*TeamHero
+	FetchedAt time.Time
+}
+
+type TeamPlayer struct {
+	AccountID int64
+	Name      string
+}
+
+type TeamResult struct {
+	MatchID  int64
+	Won      bool
+	Opponent string
+}
+
+// TeamHero is how often the team played the hero, WinRate is in percent.
+type TeamHero struct {
+	Hero    *Hero
+	Matches int
+	WinRate float64
+}
+
+var teamIDRe = regexp.MustCompile(`/esports/teams/(\d+)`)
+
+// TeamIDFromLink accepts a team ID or a dotabuff team link such as
+// "https://www.dotabuff.com/esports/teams/2163-team-liquid".
+func TeamIDFromLink(link string) (int64, error) {
+	link = strings.TrimSpace(link)
+	if id, err := strconv.ParseInt(link, 10, 64); err == nil && id > 0 {
+		return id, nil
+	}
+	m := teamIDRe.FindStringSubmatch(link)
+	if m == nil {
+		return 0, fmt.Errorf("Invalid team: %q", link)
+	}
+	return strconv.ParseInt(m[1], 10, 64)
+}
+
+// sortHeroPool orders the pool from the most played hero.
+func sortHeroPool(pool []*TeamHero) {
+	sort.SliceStable(pool, func(i, j int) bool {
+		if pool[i].Matches != pool[j].Matches {
+			return pool[i].Matches > pool[j].Matches
+		}
+		return pool[i].WinRate > pool[j].WinRate
+	})
+}
+
+// DotabuffTeam scrapes the overview, the heroes and the matches pages of
+// the team.
+func DotabuffTeam(id int64) (*TeamProfile, error) {
+	link := fmt.Sprintf("https://www.dotabuff.com/esports/teams/%d", id)
+	parsed, err := getAndParse(link)
+	if err != nil {
+		return nil, err
+	}
+	team := &TeamProfile{ID: id, Link: link, FetchedAt: time.Now()}
+	if h1 := htmlquery.FindOne(parsed, "//div[contains(@class, 'header-content-title')]//h1"); h1 != nil {
+		team.Name = strings.TrimSpace(htmlquery.InnerText(h1))
+	}
+	if team.Name == "" {
+		return nil, &ParseError{URL: link, Element: "team name"}
+	}
+	team.Roster, err = parseTeamRoster(link, parsed)
+	if err != nil {
+		// a disbanded team has no current roster, the rest of the profile
+		// is still useful
+		log.Warn().Err(err).Int64("team", id).Msg("Team roster has not been parsed")
+	}
+
+	heroesLink := link + "/heroes"
+	parsed, err = getAndParse(heroesLink)
+	if err != nil {
+		return nil, err
+	}
+	team.HeroPool, err = parseTeamHeroes(heroesLink, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	matchesLink := link + "/matches"
+	parsed, err = getAndParse(matchesLink)
+	if err != nil {
+		return nil, err
+	}
+	team.Results = parseTeamResults(id, parsed)
+	return team, nil
+}
+
+// currentRosterXPath is the section of the overview page titled as the
+// roster, the former players are listed in a section of their own.
+const currentRosterXPath = "//section[header[contains(., 'Roster') and not(contains(., 'Former'))]]"
+
+// parseTeamRoster reads the player links of the current roster section.
+func parseTeamRoster(link string, root *html.Node) ([]*TeamPlayer, error) {
+	section := htmlquery.FindOne(root, currentRosterXPath)
+	if section == nil {
+		return nil, &ParseError{URL: link, Element: "current roster section"}
+	}
+	seen := make(map[int64]bool)
+	roster := make([]*TeamPlayer, 0, 5)
+	for _, a := range htmlquery.Find(section, ".//a[starts-with(@href, '/esports/players/')]") {
+		href := strings.TrimPrefix(htmlquery.SelectAttr(a, "href"), "/esports/players/")
+		if i := strings.IndexAny(href, "-/"); i >= 0 {
+			href = href[:i]
+		}
+		id, err := strconv.ParseInt(href, 10, 64)
+		name := strings.TrimSpace(htmlquery.InnerText(a))
+		if err != nil || name == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		roster = append(roster, &TeamPlayer{AccountID: id, Name: name})
+	}
+	return roster, nil
+}
+
+// parseTeamHeroes reads the hero table: the hero link, then the number of
+// matches and the winrate as the first plain and the first percent cell.
+func parseTeamHeroes(link string, root *html.Node) ([]*TeamHero, error) {
+	rows := htmlquery.Find(root, "//table/tbody/tr[.//a[starts-with(@href, '/heroes/')]]")
+	if len(rows) == 0 {
+		return nil, &ParseError{URL: link, Element: "team hero rows"}
+	}
+	pool := make([]*TeamHero, 0, len(rows))
+	for _, tr := range rows {
+		hero, err := HeroFromTr(link, tr)
+		if err != nil {
+			return nil, err
+		}
+		entry := &TeamHero{Hero: hero, Matches: -1, WinRate: -1}
+		for _, td := range htmlquery.Find(tr, "./td") {
+			text := strings.ReplaceAll(strings.TrimSpace(htmlquery.InnerText(td)), ",", "")
+			if strings.HasSuffix(text, "%") && entry.WinRate < 0 {
+				entry.WinRate, _ = strconv.ParseFloat(strings.TrimSuffix(text, "%"), 64)
+			} else if n, err := strconv.Atoi(text); err == nil && entry.Matches < 0 {
+				entry.Matches = n
+			}
+		}
+		if entry.Matches < 0 || entry.WinRate < 0 {
+			return nil, &ParseError{URL: link, Element: "team hero matches and winrate"}
+		}
+		pool = append(pool, entry)
+	}
+	sortHeroPool(pool)
+	return pool, nil
+}
+
+func parseTeamResults(teamID int64, root *html.Node) []*TeamResult {
+	self := fmt.Sprintf("/esports/teams/%d", teamID)
+	results := make([]*TeamResult, 0, teamRecentResults)
+	for _, tr := range htmlquery.Find(root, "//table/tbody/tr[.//a[starts-with(@href, '/matches/')]]") {
+		if len(results) == teamRecentResults {
+			break
+		}
+		a := htmlquery.FindOne(tr, ".//a[starts-with(@href, '/matches/')]")
+		matchId, err := MatchIDFromDBLink("https://www.dotabuff.com" + htmlquery.SelectAttr(a, "href"))
+		if err != nil {
+			continue
+		}
+		result := &TeamResult{
+			MatchID: matchId,
+			Won:     htmlquery.FindOne(tr, ".//*[contains(concat(' ', normalize-space(@class), ' '), ' won ')]") != nil,
+		}
+		for _, t := range htmlquery.Find(tr, ".//a[starts-with(@href, '/esports/teams/')]") {
+			if !strings.HasPrefix(htmlquery.SelectAttr(t, "href"), self) {
+				result.Opponent = strings.TrimSpace(htmlquery.InnerText(t))
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+type cachedTeam struct {
+	team    *TeamProfile
+	builtAt time.Time
+}
+
+// TeamProfile returns the profile of the team with the link or ID from
+// the data source, it is cached for a few hours and stored in MySQL.
+func (e *Engine) TeamProfile(link string) (*TeamProfile, error) {
+	id, err := TeamIDFromLink(link)
+	if err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	cached, ok := e.teams[id]
+	e.lock.Unlock()
+	if ok && time.Since(cached.builtAt) < teamProfileTTL {
+		return cached.team, nil
+	}
+	team, err := e.source.Team(id)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int64("team", id).Str("name", team.Name).Int("heroes", len(team.HeroPool)).Msg("Team has been fetched")
+	e.lock.Lock()
+	e.teams[id] = &cachedTeam{team: team, builtAt: time.Now()}
+	e.lock.Unlock()
+	if e.mysql != nil {
+		go e.mysql.InsertTeam(team)
+	}
+	return team, nil
+}
+
+// TeamHeroPool returns the heroes the team played the most, at most limit
+// of them when limit is positive.
+func (e *Engine) TeamHeroPool(link string, limit int) ([]*TeamHero, error) {
+	team, err := e.TeamProfile(link)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(team.HeroPool) > limit {
+		return team.HeroPool[:limit], nil
+	}
+	return team.HeroPool, nil
+}
@@ -0,0 +1,63 @@
+package dotabuff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antchfx/htmlquery"
+)
+
+const teamLink = "https://www.dotabuff.com/esports/teams/2163"
+
+func TestParseTeamRoster(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "teams", "team_liquid.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	root, err := htmlquery.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roster, err := parseTeamRoster(teamLink, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Topson", "miCKe", "Nisha", "Boxi", "Insania"}
+	if len(roster) != len(want) {
+		t.Fatalf("got %d players, want %d", len(roster), len(want))
+	}
+	for i, p := range roster {
+		if p.Name != want[i] {
+			t.Fatalf("got %q at %d, want %q", p.Name, i, want[i])
+		}
+	}
+	if roster[0].AccountID != 94054712 {
+		t.Fatalf("got account %d", roster[0].AccountID)
+	}
+}
+
+func TestParseTeamRosterMissing(t *testing.T) {
+	root, err := htmlquery.Parse(strings.NewReader(`<html><body><a href="/esports/players/1-former">Former</a></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseTeamRoster(teamLink, root)
+	if !IsLayoutChanged(err) {
+		t.Fatalf("got %v, want a ParseError", err)
+	}
+}
+
+func TestTeamIDFromLink(t *testing.T) {
+	for _, link := range []string{"2163", " https://www.dotabuff.com/esports/teams/2163-team-liquid ", teamLink + "/matches"} {
+		id, err := TeamIDFromLink(link)
+		if err != nil || id != 2163 {
+			t.Errorf("%q: got %d, %v", link, id, err)
+		}
+	}
+	if _, err := TeamIDFromLink("liquid"); err == nil {
+		t.Error("invalid link did not fail")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rs/zerolog/log"
@@ -13,12 +14,141 @@ type TelegramBot struct {
 	Engine *Engine
 	Token  string
 	Bot    *tgbotapi.BotAPI
+
+	// counter filters chosen with /filter and algorithms chosen with
+	// /algorithm, per chat
+	filters     map[int64]PickOptions
+	filtersLock sync.Mutex
+
+	// chats subscribed to live match predictions with /live
+	liveChats map[int64]bool
 }
 
 func NewTelegramBot(engine *Engine, token string) *TelegramBot {
 	return &TelegramBot{
-		Engine: engine,
-		Token:  token,
+		Engine:    engine,
+		Token:     token,
+		filters:   make(map[int64]PickOptions),
+		liveChats: make(map[int64]bool),
+	}
+}
+
+func (b *TelegramBot) chatOptions(chatId int64) PickOptions {
+	b.filtersLock.Lock()
+	defer b.filtersLock.Unlock()
+	return b.filters[chatId]
+}
+
+// handleFilter implements "/filter [reset | pro | <window> <bracket>]".
+func (b *TelegramBot) handleFilter(chatId int64, args string) string {
+	args = strings.TrimSpace(args)
+	b.filtersLock.Lock()
+	defer b.filtersLock.Unlock()
+	algorithm := b.filters[chatId].Algorithm
+	switch args {
+	case "":
+		return fmt.Sprintf("Using %s.\nChange it with /filter <week|month|patch> <%s>, /filter pro, or /filter reset.", b.filters[chatId], strings.Join(CounterBrackets(), "|"))
+	case "reset":
+		b.filters[chatId] = PickOptions{Algorithm: algorithm}
+		return "Using default counters."
+	case CountersPro:
+		opts := PickOptions{Counters: CountersPro, Algorithm: algorithm}
+		b.filters[chatId] = opts
+		return fmt.Sprintf("Using %s.", opts)
+	}
+	filter, err := ParseCounterFilter(args)
+	if err != nil {
+		return err.Error()
+	}
+	b.filters[chatId] = PickOptions{Filter: filter, Algorithm: algorithm}
+	return fmt.Sprintf("Using %s.", filter)
+}
+
+// handleAlgorithm implements "/algorithm [reset | <version>]".
+func (b *TelegramBot) handleAlgorithm(chatId int64, args string) string {
+	args = strings.TrimSpace(args)
+	b.filtersLock.Lock()
+	defer b.filtersLock.Unlock()
+	opts := b.filters[chatId]
+	switch args {
+	case "":
+		version := opts.Algorithm
+		if version == "" {
+			version = DefaultAlgorithm
+		}
+		return fmt.Sprintf("Using algorithm %s.\nChange it with /algorithm <%s> or /algorithm reset.", version, strings.Join(PredictorVersions(), "|"))
+	case "reset":
+		opts.Algorithm = ""
+		b.filters[chatId] = opts
+		return fmt.Sprintf("Using the default algorithm %s.", DefaultAlgorithm)
+	}
+	p, err := PredictorFor(args)
+	if err != nil {
+		return err.Error()
+	}
+	opts.Algorithm = p.Version()
+	b.filters[chatId] = opts
+	return fmt.Sprintf("Using algorithm %s.", opts.Algorithm)
+}
+
+// handleTeam implements "/team <dotabuff team link or ID>" and lists the
+// most played heroes of the team.
+func (b *TelegramBot) handleTeam(args string) string {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return "Use /team <dotabuff team link or ID>."
+	}
+	team, err := b.Engine.TeamProfile(args)
+	if err != nil {
+		return describeError("Error fetching team", err)
+	}
+	pool, _ := b.Engine.TeamHeroPool(team.Link, 10)
+	var sb strings.Builder
+	sb.WriteString(team.Name + "\n")
+	for _, p := range team.Roster {
+		sb.WriteString(p.Name + " ")
+	}
+	sb.WriteString("\nMost played heroes:\n")
+	for _, h := range pool {
+		sb.WriteString(fmt.Sprintf("%s: %d matches, %.2f%%\n", h.Hero.Name, h.Matches, h.WinRate))
+	}
+	return sb.String()
+}
+
+// handleLive implements "/live [on | off]".
+func (b *TelegramBot) handleLive(chatId int64, args string) string {
+	b.filtersLock.Lock()
+	defer b.filtersLock.Unlock()
+	switch strings.TrimSpace(args) {
+	case "on":
+		b.liveChats[chatId] = true
+		return "You will get a prediction of every pro match once its draft is complete."
+	case "off":
+		delete(b.liveChats, chatId)
+		return "Live predictions are off."
+	}
+	return "Use /live on or /live off."
+}
+
+// SendLivePrediction sends the prediction to every chat subscribed with
+// /live, it is meant to be subscribed to the LivePoller.
+func (b *TelegramBot) SendLivePrediction(lp *LivePrediction) {
+	if b.Bot == nil {
+		return
+	}
+	b.filtersLock.Lock()
+	chats := make([]int64, 0, len(b.liveChats))
+	for chatId := range b.liveChats {
+		chats = append(chats, chatId)
+	}
+	b.filtersLock.Unlock()
+	text := fmt.Sprintf("%s vs %s (match %d)\nRadiant winrate: %.2f%%\nDire winrate: %.2f%%",
+		lp.Match.RadiantTeam.Name, lp.Match.DireTeam.Name, lp.Match.MatchID, lp.Prediction.RadiantWin, lp.Prediction.DireWin)
+	for _, chatId := range chats {
+		_, err := b.Bot.Send(tgbotapi.NewMessage(chatId, text))
+		if err != nil {
+			log.Error().Err(err).Int64("chat", chatId).Msg("Error sending live prediction")
+		}
 	}
 }
 
@@ -31,26 +161,35 @@ func (l *TGLogger) Printf(format string, v ...interface{}) {
 func (l *TGLogger) Println(v ...interface{}) {
 	log.Info().Msg(fmt.Sprint(v...))
 }
-func (b *TelegramBot) SendPickWinRatesToUser(chatId int64, msgId int, split []string) error {
+func (b *TelegramBot) SendPickWinRatesToUser(chatId int64, msgId int, split []string, opts PickOptions) error {
 	reply := msgId != 0
-	path, err := b.Engine.GenerateHeatMap(split)
+	path, err := b.Engine.GenerateHeatMap(split, opts)
 	if err != nil {
 		log.Error().Err(err).Msg("Error generating heatmap")
-		msg := tgbotapi.NewMessage(chatId, fmt.Sprintf("Error generating heatmap: %v", err))
+		msg := tgbotapi.NewMessage(chatId, describeError("Error generating heatmap", err))
 		if reply {
 			msg.ReplyToMessageID = msgId
 		}
 		b.Bot.Send(msg)
 		return err
 	}
+	return b.sendHeatMap(chatId, msgId, path, fmt.Sprintf("Here is the counter heatmap of the winrate of the heroes you selected (%s).", opts))
+}
+
+func (b *TelegramBot) sendHeatMap(chatId int64, msgId int, path string, caption string) error {
 	log.Info().Msg("Sending heatmap...")
-	file, _ := os.Open(path)
+	file, err := os.Open(path)
+	if err != nil {
+		log.Error().Err(err).Msg("Error opening heatmap")
+		return err
+	}
+	defer file.Close()
 	reader := tgbotapi.FileReader{Name: "image.jpg", Reader: file}
 	photo := tgbotapi.NewPhoto(chatId, reader)
-	if reply {
+	if msgId != 0 {
 		photo.ReplyToMessageID = msgId
 	}
-	photo.Caption = `Here is the counter heatmap of the winrate of the heroes you selected.`
+	photo.Caption = caption
 	_, err = b.Bot.Send(photo)
 	if err != nil {
 		log.Error().Err(err).Msg("Error sending photo")
@@ -80,42 +219,94 @@ func (b *TelegramBot) Start() error {
 	updates := bot.GetUpdatesChan(u)
 	for update := range updates {
 		if update.Message != nil {
-			text := update.Message.Text
-			split := strings.Split(text, ",")
-			log.Info().Str("username", update.Message.From.UserName).Str("text", text).Msg("Received message")
-			if text == "/start" || text == "/help" {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Hello! I'm a bot that \n 123123")
-				msg.ReplyToMessageID = update.Message.MessageID
-				bot.Send(msg)
-				continue
-			} else if len(split) == 10 {
-				b.SendPickWinRatesToUser(update.Message.Chat.ID, update.Message.MessageID, split)
-			} else if strings.HasPrefix(text, "https://www.dotabuff.com/matches/") {
-				match, err := ExtractHerosFromDBLink(text)
-				if err != nil {
-					log.Error().Err(err).Msg("Error extracting heroes from match")
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Error extracting heroes from match: %v", err))
-					msg.ReplyToMessageID = update.Message.MessageID
-					bot.Send(msg)
-					continue
-				}
-				rw, dw, err := b.Engine.PickWinRateFromDBMatch(match)
-				if err != nil {
-					log.Error().Err(err).Msg("Error fetching pick winrate")
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Error fetching pick winrate: %v", err))
-					msg.ReplyToMessageID = update.Message.MessageID
-					bot.Send(msg)
-					continue
-				}
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Radiant winrate: %.2f%%\nDire winrate: %.2f%%", rw, dw))
-				msg.ReplyToMessageID = update.Message.MessageID
-				bot.Send(msg)
-			} else {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "I'm sorry, I didn't understand that. Please type /start or /help for instructions.")
-				msg.ReplyToMessageID = update.Message.MessageID
-				bot.Send(msg)
-			}
+			b.handleMessage(update)
 		}
 	}
 	panic("unreachable")
 }
+
+// describeError turns an error into a message for the user, layout changes
+// are reported as such instead of dumping the parser details.
+func describeError(prefix string, err error) string {
+	if IsLayoutChanged(err) {
+		return "Sorry, dotabuff layout changed and I can't read it right now. Please try again later."
+	}
+	return fmt.Sprintf("%s: %v", prefix, err)
+}
+
+func (b *TelegramBot) handleMessage(update tgbotapi.Update) {
+	bot := b.Bot
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Msg("Recovered while handling telegram message")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Sorry, something went wrong while handling your message.")
+			msg.ReplyToMessageID = update.Message.MessageID
+			bot.Send(msg)
+		}
+	}()
+	text := update.Message.Text
+	split := strings.Split(text, ",")
+	log.Info().Str("username", update.Message.From.UserName).Str("text", text).Msg("Received message")
+	if text == "/start" || text == "/help" {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Hello! I'm a bot that \n 123123")
+		msg.ReplyToMessageID = update.Message.MessageID
+		bot.Send(msg)
+		return
+	} else if text == "/filter" || strings.HasPrefix(text, "/filter ") {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.handleFilter(update.Message.Chat.ID, strings.TrimPrefix(text, "/filter")))
+		msg.ReplyToMessageID = update.Message.MessageID
+		bot.Send(msg)
+	} else if text == "/algorithm" || strings.HasPrefix(text, "/algorithm ") {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.handleAlgorithm(update.Message.Chat.ID, strings.TrimPrefix(text, "/algorithm")))
+		msg.ReplyToMessageID = update.Message.MessageID
+		bot.Send(msg)
+	} else if text == "/live" || strings.HasPrefix(text, "/live ") {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.handleLive(update.Message.Chat.ID, strings.TrimPrefix(text, "/live")))
+		msg.ReplyToMessageID = update.Message.MessageID
+		bot.Send(msg)
+	} else if text == "/team" || strings.HasPrefix(text, "/team ") {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.handleTeam(strings.TrimPrefix(text, "/team")))
+		msg.ReplyToMessageID = update.Message.MessageID
+		bot.Send(msg)
+	} else if len(split) == 10 {
+		b.SendPickWinRatesToUser(update.Message.Chat.ID, update.Message.MessageID, split, b.chatOptions(update.Message.Chat.ID))
+	} else if LooksLikeMatchRef(text) {
+		matchId, err := ParseMatchRef(text)
+		if err != nil {
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Invalid match link: %v", err))
+			msg.ReplyToMessageID = update.Message.MessageID
+			bot.Send(msg)
+			return
+		}
+		match, err := b.Engine.Match(matchId)
+		if err != nil {
+			log.Error().Err(err).Msg("Error extracting heroes from match")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, describeError("Error extracting heroes from match", err))
+			msg.ReplyToMessageID = update.Message.MessageID
+			bot.Send(msg)
+			return
+		}
+		opts := b.chatOptions(update.Message.Chat.ID)
+		rw, dw, err := b.Engine.PickWinRateFromDBMatch(match, opts)
+		if err != nil {
+			log.Error().Err(err).Msg("Error fetching pick winrate")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, describeError("Error fetching pick winrate", err))
+			msg.ReplyToMessageID = update.Message.MessageID
+			bot.Send(msg)
+			return
+		}
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Radiant winrate: %.2f%%\nDire winrate: %.2f%%\n(%s)", rw, dw, opts))
+		msg.ReplyToMessageID = update.Message.MessageID
+		bot.Send(msg)
+		path, err := b.Engine.GenerateMatchHeatMap(match, opts)
+		if err != nil {
+			log.Error().Err(err).Msg("Error generating match heatmap")
+			return
+		}
+		b.sendHeatMap(update.Message.Chat.ID, update.Message.MessageID, path, "Counter heatmap of the match, heroes are ordered by position.")
+	} else {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "I'm sorry, I didn't understand that. Please type /start or /help for instructions.")
+		msg.ReplyToMessageID = update.Message.MessageID
+		bot.Send(msg)
+	}
+}
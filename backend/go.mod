@@ -2,20 +2,22 @@ module github.io/sammers21/owl-esports/backend
 
 go 1.22.1
 
-require github.com/rs/zerolog v1.33.0
+require (
+	github.com/antchfx/htmlquery v1.3.2
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	github.com/google/uuid v1.6.0
+	github.com/rs/cors v1.11.0
+	github.com/rs/zerolog v1.33.0
+	golang.org/x/net v0.7.0
+	golang.org/x/text v0.7.0
+)
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/antchfx/htmlquery v1.3.2 // indirect
 	github.com/antchfx/xpath v1.3.1 // indirect
-	github.com/go-sql-driver/mysql v1.8.1 // indirect
-	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/rs/cors v1.11.0 // indirect
-	golang.org/x/net v0.7.0 // indirect
 	golang.org/x/sys v0.22.0 // indirect
-	golang.org/x/text v0.7.0 // indirect
 )
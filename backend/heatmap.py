@@ -20,6 +20,7 @@ if __name__ == '__main__':
     parser.add_argument("--heroes", type=str, help="heroes picked")
     parser.add_argument('--winrates', type=str, help='winrates')
     parser.add_argument('--output', type=str, help='output')
+    parser.add_argument('--radiant-win', type=float, help='normalized radiant win chance for the title')
     args = parser.parse_args()
     print("Heroes: ", args.heroes)
     print("Winrates: ", args.winrates)
@@ -83,6 +84,8 @@ if __name__ == '__main__':
             text = ax.text(j, i, f"{np_winrates[i, j]:.2f}%", ha="center", va="center", color="black")
     # ax.set_title("Dota 2 Winrates Heatmap")
     pick_winrate = np.mean(np_winrates[5, :5])
+    if args.radiant_win is not None:
+        pick_winrate = args.radiant_win
     if pick_winrate > 50:
         title = "Radinant/Dire win chance: {:.2f}%/{:.2f}%".format(pick_winrate, 100 - pick_winrate)
         # set title color to green
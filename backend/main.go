@@ -2,7 +2,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -14,17 +16,126 @@ import (
 func main() {
 	telegramTokenCli := flag.String("t", "", "Telegram bot token")
 	mysqlCli := flag.String("m", "", "MySQL connection string")
+	sourceCli := flag.String("source", "dotabuff", "Hero data source: dotabuff or opendota")
+	httpTimeoutCli := flag.Duration("http-timeout", 30*time.Second, "Timeout of a single HTTP request")
+	maxRetriesCli := flag.Int("max-retries", 5, "Max retries of a failed HTTP request")
+	concurrencyCli := flag.Int("concurrency", 4, "Number of heroes whose counters are fetched in parallel")
+	rpsCli := flag.Float64("rps", 2, "Max requests per second to a single host, 0 disables the limit")
+	recordCli := flag.String("record", "", "Record every fetched page to this cassette directory")
+	replayCli := flag.String("replay", "", "Serve every page from this cassette directory instead of the network")
+	crawlLeagueCli := flag.String("crawl-league", "", "Store every match of this dotabuff league (link or ID) and exit")
+	cacheCli := flag.String("cache", "fs", "Cache store of the fetched data: fs, memory or mysql")
+	cacheDirCli := flag.String("cache-dir", "data", "Directory of the fs cache store")
+	snapshotCli := flag.String("snapshot", "", "Start from this dataset snapshot instead of fetching the data")
+	offlineCli := flag.Bool("offline", false, "Never refresh the data loaded from the snapshot")
+	exportSnapshotCli := flag.String("export-snapshot", "", "Load the data, write a dataset snapshot to this path and exit")
+	livePollCli := flag.Duration("live-poll", 0, "Poll live pro matches at this interval and predict them, 0 disables")
+	crawlStateCli := flag.String("crawl-state", "data/crawl", "Directory keeping the progress of league crawls")
+	algorithmCli := flag.String("algorithm", dotabuff.DefaultAlgorithm, "Algorithm version of the predictions made by the crawler, the live poller and -predict-files")
+	shadowCli := flag.Bool("shadow", true, "Also store the predictions of every other algorithm version, -shadow=false stores only the selected one")
+	backtestCli := flag.String("backtest", "", "Replay finished matches through these comma separated algorithm versions, or all, and exit")
+	backtestFilesCli := flag.String("backtest-files", "", "Comma separated OpenDota or Valve JSON files and directories to backtest instead of the MySQL matches")
+	calibrationCli := flag.Bool("calibration", false, "Print the calibration report of the stored predictions and exit")
+	calibrationPlotCli := flag.String("calibration-plot", "", "Also plot the reliability diagram of the calibration report to this .png or .svg file")
+	predictFilesCli := flag.String("predict-files", "", "Predict the matches of these comma separated OpenDota or Valve JSON files and directories and exit")
+	storeFilesCli := flag.Bool("store-files", false, "Also store the predictions of -predict-files in MySQL")
 	flag.Parse()
 	telegramToken := *telegramTokenCli
 	mysql := *mysqlCli
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	dotabuff.DefaultFetcher = dotabuff.NewFetcher(*httpTimeoutCli, *maxRetriesCli)
+	dotabuff.DefaultFetcher.Limiter = dotabuff.NewHostRateLimiter(*rpsCli, 1)
+	if *recordCli != "" && *replayCli != "" {
+		log.Fatal().Msg("Only one of record and replay can be used")
+		return
+	}
+	if *recordCli != "" || *replayCli != "" {
+		dir, mode := *recordCli, dotabuff.CassetteRecord
+		if *replayCli != "" {
+			dir, mode = *replayCli, dotabuff.CassetteReplay
+		}
+		cassette, err := dotabuff.NewCassette(dir, mode)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening cassette")
+			return
+		}
+		log.Info().Str("dir", dir).Bool("replay", mode == dotabuff.CassetteReplay).Msg("Using cassette")
+		dotabuff.DefaultFetcher.Cassette = cassette
+	}
 	mysqlDb, err := dotabuff.NewMySQL(mysql)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error connecting to MySQL")
 		return
 	}
-	engine := dotabuff.NewEngine(mysqlDb)
+	source, err := dotabuff.NewDataSource(*sourceCli)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error creating data source")
+		return
+	}
+	log.Info().Str("source", *sourceCli).Msg("Using hero data source")
+	engine := dotabuff.NewEngine(mysqlDb, source)
+	engine.Concurrency = *concurrencyCli
+	engine.Shadow = *shadowCli
+	_, err = dotabuff.PredictorFor(*algorithmCli)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error selecting algorithm")
+		return
+	}
+	opts := dotabuff.PickOptions{Algorithm: *algorithmCli}
+	cache, err := dotabuff.NewCacheStore(*cacheCli, *cacheDirCli, mysqlDb)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error creating cache store")
+		return
+	}
+	engine.SetCacheStore(cache)
+	if *offlineCli && *snapshotCli == "" {
+		log.Fatal().Msg("Offline mode needs a snapshot")
+		return
+	}
+	if *snapshotCli != "" {
+		_, err = engine.ImportSnapshot(*snapshotCli)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error importing snapshot")
+			return
+		}
+	}
+	if *exportSnapshotCli != "" {
+		if *snapshotCli == "" {
+			loadEngine(engine)
+		}
+		_, err = engine.ExportSnapshot(*exportSnapshotCli)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error exporting snapshot")
+		}
+		return
+	}
+	if *crawlLeagueCli != "" {
+		if mysqlDb == nil {
+			log.Fatal().Msg("Crawling a league stores its matches and needs MySQL, set -m")
+			return
+		}
+		if *snapshotCli == "" {
+			loadEngine(engine)
+		}
+		crawlLeague(engine, *crawlLeagueCli, *crawlStateCli, opts)
+		return
+	}
+	if *backtestCli != "" {
+		backtest(engine, mysqlDb, *backtestCli, *backtestFilesCli, opts)
+		return
+	}
+	if *calibrationCli {
+		calibration(engine, *calibrationPlotCli)
+		return
+	}
+	if *predictFilesCli != "" {
+		if *snapshotCli == "" {
+			loadEngine(engine)
+		}
+		predictFiles(engine, strings.Split(*predictFilesCli, ","), opts, *storeFilesCli)
+		return
+	}
 	var telegramBot *dotabuff.TelegramBot
 	if telegramToken != "" {
 		log.Info().Str("token", telegramToken).Msg("Starting telegram bot")
@@ -33,25 +144,138 @@ func main() {
 	} else {
 		log.Info().Msg("Telegram bot token not provided")
 	}
+	if *livePollCli > 0 {
+		poller := dotabuff.NewLivePoller(engine, dotabuff.NewOpenDotaLiveFeed(dotabuff.OpenDotaBaseURL), *livePollCli)
+		poller.Options = opts
+		if telegramBot != nil {
+			poller.Subscribe(telegramBot.SendLivePrediction)
+		}
+		go poller.Start()
+	}
 	server := dotabuff.NewServer(engine, telegramBot)
 	go server.Start(8080)
 	engineUpd := func() {
-		log.Info().Msg("Updating heroes and counters data from dotabuff")
+		log.Info().Msg("Updating heroes and counters data")
 		err := engine.LoadHeroes()
-		if err != nil {
-			log.Fatal().Err(err).Msg("Error loading heroes")
+		if err == nil {
+			err = engine.LoadCounters()
 		}
-		err = engine.LoadCounters()
-		if err != nil {
-			log.Fatal().Err(err).Msg("Error loading counters")
+		if err == nil {
+			return
 		}
+		// keep serving the snapshot or the previous counters while the
+		// sources are down
+		if *snapshotCli != "" || engine.Loaded() {
+			log.Error().Err(err).Msg("Error updating data")
+			return
+		}
+		log.Fatal().Err(err).Msg("Error loading data")
 	}
-	go func() {
-		engineUpd()
-		// update every 30 minutes
-		for range time.Tick(30 * time.Minute) {
+	if !*offlineCli {
+		go func() {
 			engineUpd()
-		}
-	}()
+			// update every 30 minutes
+			for range time.Tick(30 * time.Minute) {
+				engineUpd()
+			}
+		}()
+	}
 	select {}
 }
+
+func loadEngine(engine *dotabuff.Engine) {
+	err := engine.LoadHeroes()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error loading heroes")
+	}
+	err = engine.LoadCounters()
+	if err != nil && engine.Loaded() {
+		log.Error().Err(err).Msg("Some counters could not be loaded")
+	} else if err != nil {
+		log.Fatal().Err(err).Msg("Error loading counters")
+	}
+}
+
+func crawlLeague(engine *dotabuff.Engine, league string, stateDir string, opts dotabuff.PickOptions) {
+	leagueID, err := dotabuff.ParseLeagueID(league)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error parsing league")
+	}
+	crawler := dotabuff.NewCrawler(engine, stateDir)
+	crawler.Options = opts
+	stats, err := crawler.CrawlLeague(leagueID)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error crawling league")
+	}
+	log.Info().Int("matches", stats.Matches).Int("stored", stats.Stored).Int("skipped", stats.Skipped).Int("failed", stats.Failed).Msg("Crawl finished")
+}
+
+func predictFiles(engine *dotabuff.Engine, paths []string, opts dotabuff.PickOptions, store bool) {
+	results, err := engine.PredictMatchFiles(paths, opts, store)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error reading match files")
+	}
+	predicted, correct := 0, 0
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Printf("%s\t-\terror: %v\n", res.Path, res.Err)
+			continue
+		}
+		predicted++
+		winner := res.Match.DireTeam.Name
+		if res.Match.RadiantWon {
+			winner = res.Match.RadiantTeam.Name
+		}
+		if (res.RadiantWin > res.DireWin) == res.Match.RadiantWon {
+			correct++
+		}
+		fmt.Printf("%s\t%d\t%s %.2f%% vs %s %.2f%%\twinner: %s\n", res.Path, res.Match.MatchID,
+			res.Match.RadiantTeam.Name, res.RadiantWin, res.Match.DireTeam.Name, res.DireWin, winner)
+	}
+	log.Info().Int("matches", len(results)).Int("predicted", predicted).Int("correct", correct).Msg("Match files predicted")
+}
+
+// backtest runs without loading the engine, past patches come from the
+// cache store and the current one from the snapshot when there is one.
+func backtest(engine *dotabuff.Engine, mysql *dotabuff.MySQL, algorithms string, files string, opts dotabuff.PickOptions) {
+	var matches []*dotabuff.BacktestMatch
+	var err error
+	if files != "" {
+		matches, err = dotabuff.BacktestMatchesFromFiles(strings.Split(files, ","))
+	} else if mysql != nil {
+		matches, err = mysql.BacktestMatches()
+	} else {
+		log.Fatal().Msg("Backtest needs MySQL or match files")
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error reading backtest matches")
+	}
+	versions := strings.Split(algorithms, ",")
+	if algorithms == "all" {
+		versions = dotabuff.PredictorVersions()
+	}
+	opts.Algorithm = ""
+	backtester := dotabuff.NewBacktester(engine, opts)
+	for _, version := range versions {
+		report, err := backtester.Run(strings.TrimSpace(version), matches)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error running backtest")
+		}
+		fmt.Println(report)
+	}
+}
+
+func calibration(engine *dotabuff.Engine, plot string) {
+	report, err := engine.CalibrationReport()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error building calibration report")
+	}
+	fmt.Print(report)
+	if plot != "" {
+		err = report.ReliabilityDiagram(plot)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error plotting reliability diagram")
+		}
+		log.Info().Str("path", plot).Msg("Reliability diagram has been written")
+	}
+}
@@ -0,0 +1,32 @@
+import matplotlib.pyplot as plt
+import argparse
+import json
+
+
+if __name__ == '__main__':
+    parser = argparse.ArgumentParser()
+    parser.add_argument('--data', type=str, help='calibration report as json')
+    parser.add_argument('--output', type=str, help='output, .png or .svg')
+    args = parser.parse_args()
+    print("Output: ", args.output)
+    plt.ioff()
+    report = json.loads(args.data)
+    fig, ax = plt.subplots(figsize=(8, 8))
+    ax.plot([50, 100], [50, 100], linestyle='--', color='gray', label='Perfect calibration')
+    for algorithm in report['algorithms']:
+        buckets = [b for b in algorithm['buckets'] if b['predictions'] > 0]
+        confidence = [b['mean_confidence'] for b in buckets]
+        hit_rate = [b['hit_rate'] for b in buckets]
+        label = "{} ({} predictions, {:.2f}%)".format(algorithm['algorithm'], algorithm['predictions'], algorithm['accuracy'])
+        ax.plot(confidence, hit_rate, marker='o', label=label)
+        for b in buckets:
+            ax.annotate(str(b['predictions']), (b['mean_confidence'], b['hit_rate']), textcoords="offset points", xytext=(0, 6), ha='center', fontsize=8)
+    ax.set_xlim(50, 100)
+    ax.set_ylim(0, 100)
+    ax.set_xlabel("Predicted win chance of the favored side %")
+    ax.set_ylabel("Favored side won %")
+    ax.set_title("Reliability diagram")
+    ax.legend(loc='lower right')
+    fig.tight_layout()
+    plt.savefig(args.output, bbox_inches='tight')
+    plt.close(fig)